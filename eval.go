@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kubewarden/cel-policy/internal/settings"
+	"github.com/kubewarden/cel-policy/internal/validate"
+)
+
+// evalCommand evaluates an expression against the admission request read from stdin.
+// The expression is given with the `--expression` flag, or it is taken from
+// the variable or the validation of the settings file selected with the
+// `--variable` and `--validation` flags. Validations are selected by name or index.
+func evalCommand(args []string, input []byte) ([]byte, error) {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	expression := flags.String("expression", "", "CEL expression to evaluate")
+	settingsPath := flags.String("settings", "", "path to the JSON settings file providing the variables and params")
+	variable := flags.String("variable", "", "name of the settings variable to evaluate")
+	validation := flags.String("validation", "", "name or index of the settings validation to evaluate")
+	explain := flags.Bool("explain", false, "report the sub-expressions that evaluated to false")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	evalRequest := validate.EvalRequest{
+		Request:    input,
+		Expression: *expression,
+		Variable:   *variable,
+		Validation: *validation,
+		Explain:    *explain,
+	}
+
+	if *settingsPath != "" {
+		settingsData, err := os.ReadFile(*settingsPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read settings: %w", err)
+		}
+
+		evalRequest.Settings = settings.Settings{}
+		if err = json.Unmarshal(settingsData, &evalRequest.Settings); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal settings: %w", err)
+		}
+	}
+
+	return validate.Eval(evalRequest)
+}
@@ -6,6 +6,7 @@ import (
 	"reflect"
 
 	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/ext"
@@ -24,7 +25,8 @@ type Compiler struct {
 // will not complain about undeclared variables.
 type variables struct{}
 
-func NewCompiler() (*Compiler, error) {
+// NewCompiler returns a compiler whose `kw.sigstore` library can use the given trust roots.
+func NewCompiler(trustRoots ...library.SigstoreTrustRoot) (*Compiler, error) {
 	env, err := cel.NewEnv(
 		// Kubernetes 1.29 options
 		cel.HomogeneousAggregateLiterals(),
@@ -67,9 +69,12 @@ func NewCompiler() (*Compiler, error) {
 		// Kubewarden host capabilities libraries
 		library.Kubernetes(),
 		library.OCI(),
-		library.Sigstore(),
+		library.Sigstore(trustRoots...),
 		library.Crypto(),
 		library.Net(),
+
+		// Kubewarden libraries evaluated without calling the host
+		library.Hash(),
 	)
 	if err != nil {
 		return nil, err
@@ -81,7 +86,19 @@ func NewCompiler() (*Compiler, error) {
 func (c *Compiler) CompileCELExpression(expression string) (*cel.Ast, error) {
 	ast, issues := c.env.Compile(expression)
 	if issues != nil && issues.Err() != nil {
-		return nil, errors.New(issues.Err().Error())
+		return nil, newCompilationError(expression, issues)
+	}
+
+	return ast, nil
+}
+
+// ParseCELExpression parses the expression without type-checking it.
+// This is useful to inspect the expression before all the declarations
+// it refers to are available in the environment.
+func (c *Compiler) ParseCELExpression(expression string) (*cel.Ast, error) {
+	ast, issues := c.env.Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, newCompilationError(expression, issues)
 	}
 
 	return ast, nil
@@ -103,6 +120,33 @@ func (c *Compiler) EvalCELExpression(
 	return val, nil
 }
 
+// ConstantValue folds the constant sub-expressions of the checked AST and returns
+// the resulting value when the whole expression is reduced to a literal.
+// Expressions using the Kubewarden host capabilities libraries are not folded,
+// since folding would perform the host calls.
+func (c *Compiler) ConstantValue(ast *cel.Ast) (ref.Val, bool) {
+	if UsesHostCapabilities(ast) {
+		return nil, false
+	}
+
+	folder, err := cel.NewConstantFoldingOptimizer()
+	if err != nil {
+		return nil, false
+	}
+
+	optimized, issues := cel.NewStaticOptimizer(folder).Optimize(c.env, ast)
+	if issues != nil && issues.Err() != nil {
+		return nil, false
+	}
+
+	expr := optimized.NativeRep().Expr()
+	if expr.Kind() != celast.LiteralKind {
+		return nil, false
+	}
+
+	return expr.AsLiteral(), true
+}
+
 func (c *Compiler) ValidateBoolExpression(expression string) error {
 	ast, err := c.CompileCELExpression(expression)
 	if err != nil {
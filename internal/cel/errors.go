@@ -0,0 +1,73 @@
+package cel
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+)
+
+// CompilationError is returned when an expression cannot be parsed or type-checked.
+// Contrary to the error returned by cel-go, it keeps the position of every issue
+// found in the expression.
+type CompilationError struct {
+	expression string
+	issues     *cel.Issues
+}
+
+func newCompilationError(expression string, issues *cel.Issues) error {
+	return &CompilationError{
+		expression: expression,
+		issues:     issues,
+	}
+}
+
+func (e *CompilationError) Error() string {
+	return e.issues.Err().Error()
+}
+
+// Issue is a single problem found while compiling an expression.
+type Issue struct {
+	// Line is the 1-based line of the expression where the issue was found.
+	Line int `json:"line"`
+	// Column is the 1-based column of the expression where the issue was found.
+	Column int `json:"column"`
+	// Message describes the issue.
+	Message string `json:"message"`
+	// Snippet is the line of the expression with a caret pointing to the column of the issue.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Issues returns the issues found while compiling the expression.
+func (e *CompilationError) Issues() []Issue {
+	source := common.NewTextSource(e.expression)
+
+	issues := make([]Issue, 0, len(e.issues.Errors()))
+	for _, err := range e.issues.Errors() {
+		issue := Issue{
+			Line:    err.Location.Line(),
+			Column:  err.Location.Column() + 1,
+			Message: err.Message,
+		}
+
+		// The display string has the issue message in the first line, followed by the snippet.
+		if _, snippet, found := strings.Cut(err.ToDisplayString(source), "\n"); found {
+			issue.Snippet = snippet
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues
+}
+
+// CompilationIssues returns the issues of the compilation error wrapped by err, if any.
+func CompilationIssues(err error) ([]Issue, bool) {
+	var compilationError *CompilationError
+	if !errors.As(err, &compilationError) {
+		return nil, false
+	}
+
+	return compilationError.Issues(), true
+}
@@ -0,0 +1,286 @@
+package cel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+)
+
+const (
+	// maxExplanations is the maximum number of failing sub-expressions reported.
+	maxExplanations = 10
+	// maxValueLength is the maximum length of a value reported in an explanation.
+	maxValueLength = 80
+)
+
+// ExplainCELExpression evaluates the expression and, when it evaluates to false,
+// returns the explanation of the result: the leaf sub-expressions that evaluated
+// to false, together with the actual values of their operands.
+// The expression is first evaluated as usual, so that the accepted requests
+// perform the same host calls as without explanations. Only when it evaluates to
+// false, it is evaluated again with state tracking and without short-circuiting
+// the logical operators, to find the failing sub-expressions.
+// The `&&` and `||` operators are traversed, as well as the `all` and `exists`
+// macros, whose predicate is evaluated again for each element of the range to
+// find the failing ones. E.g.:
+//
+//	object.spec.containers[1]: !c.securityContext.privileged is false (c.securityContext.privileged = true)
+func (c *Compiler) ExplainCELExpression(
+	vars map[string]interface{}, ast *cel.Ast,
+) (ref.Val, []string, error) {
+	val, err := c.EvalCELExpression(vars, ast)
+	if err != nil || val != types.False {
+		return val, nil, err
+	}
+
+	prog, err := c.env.Program(ast, cel.EvalOptions(cel.OptTrackState, cel.OptExhaustiveEval))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	activation, err := interpreter.NewActivation(vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// without short-circuiting, the expression can fail on the operands that
+	// were skipped, e.g. `has(x.y) && x.y.z`: the result is reported without explanation
+	_, details, err := prog.Eval(activation)
+	if err != nil {
+		return val, nil, nil //nolint:nilerr // the expression evaluated to false
+	}
+
+	e := &explainer{
+		compiler: c,
+		checked:  ast.NativeRep(),
+		programs: map[int64]cel.Program{},
+	}
+	e.explain(celast.NavigateAST(e.checked), "", activation, details.State())
+
+	return val, e.explanations(), nil
+}
+
+// explainer collects the failing sub-expressions of an expression.
+type explainer struct {
+	compiler *Compiler
+	// checked is the checked expression, used to build the programs of the
+	// sub-expressions that are evaluated again.
+	checked  *celast.AST
+	programs map[int64]cel.Program
+	failures []string
+	// omitted is the number of failures not reported because of maxExplanations.
+	omitted int
+}
+
+func (e *explainer) explanations() []string {
+	if e.omitted > 0 {
+		return append(e.failures, fmt.Sprintf("and %d more", e.omitted))
+	}
+
+	return e.failures
+}
+
+// explain looks for the reason why the expression evaluated to false.
+// The context describes the elements of the ranges being iterated, if any.
+func (e *explainer) explain(expr celast.NavigableExpr, context string, activation interpreter.Activation, state interpreter.EvalState) {
+	switch expr.Kind() { //nolint:exhaustive // the other kinds are leaves
+	case celast.CallKind:
+		call := expr.AsCall()
+		if call.FunctionName() == operators.LogicalAnd || call.FunctionName() == operators.LogicalOr {
+			for _, arg := range expr.Children() {
+				if val, found := state.Value(arg.ID()); found && val == types.False {
+					e.explain(arg, context, activation, state)
+				}
+			}
+			return
+		}
+	case celast.ComprehensionKind:
+		if e.explainQuantifier(expr, context, activation, state) {
+			return
+		}
+	}
+
+	e.leaf(expr, context, state)
+}
+
+// explainQuantifier explains the result of the `all` and `exists` macros, by
+// evaluating the predicate against each element of the range.
+// It returns false when the expression is not one of the supported macros,
+// or when no failing element is found.
+func (e *explainer) explainQuantifier(expr celast.NavigableExpr, context string, activation interpreter.Activation, state interpreter.EvalState) bool {
+	comprehension := expr.AsComprehension()
+	if comprehension.HasIterVar2() {
+		return false
+	}
+
+	// the children of a comprehension are its range, accumulator initializer,
+	// loop condition, loop step and result
+	children := expr.Children()
+	rangeExpr, step := children[0], children[3]
+
+	// `all` steps with `accu && predicate`, `exists` with `accu || predicate`
+	if step.Kind() != celast.CallKind {
+		return false
+	}
+	stepCall := step.AsCall()
+	if stepCall.FunctionName() != operators.LogicalAnd && stepCall.FunctionName() != operators.LogicalOr {
+		return false
+	}
+	stepArgs := step.Children()
+	if len(stepArgs) != 2 || stepArgs[0].Kind() != celast.IdentKind || stepArgs[0].AsIdent() != comprehension.AccuVar() {
+		return false
+	}
+	predicate := stepArgs[1]
+
+	iterRange, found := state.Value(rangeExpr.ID())
+	if !found {
+		return false
+	}
+
+	prog, err := e.program(predicate)
+	if err != nil {
+		return false
+	}
+
+	rangeText := e.unparse(rangeExpr)
+	explained := false
+
+	evalElement := func(label string, element ref.Val) {
+		elementActivation := interpreter.NewHierarchicalActivation(activation, iterVarActivation{name: comprehension.IterVar(), value: element})
+		val, details, err := prog.Eval(elementActivation)
+		if err != nil || val != types.False {
+			return
+		}
+
+		explained = true
+		e.explain(predicate, joinContext(context, label), elementActivation, details.State())
+	}
+
+	switch r := iterRange.(type) {
+	case traits.Mapper:
+		for it := r.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			evalElement(fmt.Sprintf("%s[%s]", rangeText, formatValue(key)), key)
+		}
+	case traits.Lister:
+		size, ok := r.Size().(types.Int)
+		if !ok {
+			return false
+		}
+		for index := range int64(size) {
+			evalElement(fmt.Sprintf("%s[%d]", rangeText, index), r.Get(types.Int(index)))
+		}
+	default:
+		return false
+	}
+
+	return explained
+}
+
+// leaf records the failing sub-expression, together with the values of its operands.
+// Literal operands are omitted, since their value is already part of the expression.
+func (e *explainer) leaf(expr celast.NavigableExpr, context string, state interpreter.EvalState) {
+	if len(e.failures) >= maxExplanations {
+		e.omitted++
+		return
+	}
+
+	// the operands of a call are its target, if any, and its arguments
+	var operands []celast.NavigableExpr
+	if expr.Kind() == celast.CallKind {
+		operands = expr.Children()
+	}
+
+	var values []string
+	for _, operand := range operands {
+		if operand.Kind() == celast.LiteralKind {
+			continue
+		}
+		if val, found := state.Value(operand.ID()); found {
+			values = append(values, fmt.Sprintf("%s = %s", e.unparse(operand), formatValue(val)))
+		}
+	}
+
+	failure := fmt.Sprintf("%s is false", e.unparse(expr))
+	if len(values) > 0 {
+		failure = fmt.Sprintf("%s (%s)", failure, strings.Join(values, ", "))
+	}
+
+	e.failures = append(e.failures, joinContext(context, failure))
+}
+
+// program returns the program evaluating the given sub-expression.
+func (e *explainer) program(expr celast.Expr) (cel.Program, error) {
+	if prog, found := e.programs[expr.ID()]; found {
+		return prog, nil
+	}
+
+	// the sub-expression shares the types and the references of the checked expression
+	ast := celast.NewCheckedAST(celast.NewAST(expr, e.checked.SourceInfo()), e.checked.TypeMap(), e.checked.ReferenceMap())
+
+	prog, err := e.compiler.env.PlanProgram(ast, cel.EvalOptions(cel.OptTrackState, cel.OptExhaustiveEval))
+	if err != nil {
+		return nil, err
+	}
+	e.programs[expr.ID()] = prog
+
+	return prog, nil
+}
+
+func (e *explainer) unparse(expr celast.Expr) string {
+	text, err := parser.Unparse(expr, e.checked.SourceInfo())
+	if err != nil {
+		return fmt.Sprintf("<expression %d>", expr.ID())
+	}
+
+	return text
+}
+
+// iterVarActivation binds the iteration variable of a comprehension.
+type iterVarActivation struct {
+	name  string
+	value ref.Val
+}
+
+func (a iterVarActivation) ResolveName(name string) (any, bool) {
+	if name == a.name {
+		return a.value, true
+	}
+
+	return nil, false
+}
+
+func (a iterVarActivation) Parent() interpreter.Activation {
+	return nil
+}
+
+func joinContext(context, text string) string {
+	if context == "" {
+		return text
+	}
+
+	return fmt.Sprintf("%s: %s", context, text)
+}
+
+// formatValue returns the JSON representation of the value, truncated to maxValueLength.
+func formatValue(val ref.Val) string {
+	text := fmt.Sprint(val)
+	if data, err := json.Marshal(NativeValue(val)); err == nil {
+		text = string(data)
+	}
+
+	if len(text) > maxValueLength {
+		return text[:maxValueLength] + "..."
+	}
+
+	return text
+}
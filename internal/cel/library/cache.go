@@ -0,0 +1,65 @@
+package library
+
+import "github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+
+// hostCallKey identifies a host capability call by the capability and the request payload.
+type hostCallKey struct {
+	binding   string
+	namespace string
+	operation string
+	payload   string
+}
+
+type hostCallResult struct {
+	response []byte
+	err      error
+}
+
+// hostCache memoizes the responses of the host capabilities calls, so that
+// the calls done with the same arguments by different validations, or by
+// different iterations of a comprehension, reach the policy host only once.
+// Errors are memoized as well, since the host would return them again.
+// The cache is request-scoped: it must be reset before evaluating a request.
+type hostCache struct {
+	client  capabilities.WapcClient
+	results map[hostCallKey]hostCallResult
+}
+
+func newHostCache(client capabilities.WapcClient) *hostCache {
+	return &hostCache{
+		client:  client,
+		results: map[hostCallKey]hostCallResult{},
+	}
+}
+
+// HostCall implements the capabilities.WapcClient interface.
+func (c *hostCache) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	key := hostCallKey{
+		binding:   binding,
+		namespace: namespace,
+		operation: operation,
+		payload:   string(payload),
+	}
+
+	if result, found := c.results[key]; found {
+		return result.response, result.err
+	}
+
+	response, err := c.client.HostCall(binding, namespace, operation, payload)
+	c.results[key] = hostCallResult{response: response, err: err}
+
+	return response, err
+}
+
+// uncachedHost returns a host calling the policy host directly, without memoizing the responses.
+func uncachedHost() *capabilities.Host {
+	if cache, ok := host.Client.(*hostCache); ok {
+		return &capabilities.Host{Client: cache.client}
+	}
+
+	return &host
+}
+
+func (c *hostCache) reset() {
+	c.results = map[hostCallKey]hostCallResult{}
+}
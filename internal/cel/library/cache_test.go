@@ -0,0 +1,77 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/mocks"
+	netCap "github.com/kubewarden/policy-sdk-go/pkg/capabilities/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCache(t *testing.T) {
+	mockWapcClient := &mocks.MockWapcClient{}
+	mockWapcClient.On("HostCall", "kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`)).Return([]byte(`{"ips":["1.1.1.1"]}`), nil)
+	mockWapcClient.On("HostCall", "kubewarden", "net", "v1/dns_lookup_host", []byte(`"unknown.com"`)).Return(nil, errors.New("not found"))
+
+	c := newHostCache(mockWapcClient)
+
+	for range 2 {
+		response, err := c.HostCall("kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"ips":["1.1.1.1"]}`, string(response))
+
+		_, err = c.HostCall("kubewarden", "net", "v1/dns_lookup_host", []byte(`"unknown.com"`))
+		require.EqualError(t, err, "not found")
+	}
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 2)
+
+	c.reset()
+
+	_, err := c.HostCall("kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`))
+	require.NoError(t, err)
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 3)
+}
+
+func TestHostCacheSharedByExpressions(t *testing.T) {
+	response, err := json.Marshal(netCap.LookupHostResponse{Ips: []string{"1.1.1.1"}})
+	require.NoError(t, err)
+
+	mockWapcClient := &mocks.MockWapcClient{}
+	mockWapcClient.On("HostCall", "kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`)).Return(response, nil)
+
+	setHostClient(t, mockWapcClient)
+
+	env, err := cel.NewEnv(
+		Net(),
+	)
+	require.NoError(t, err)
+
+	for _, expression := range []string{
+		"kw.net.lookupHost('example.com').size() == 1",
+		"kw.net.lookupHost('example.com').all(ip, ip == '1.1.1.1' || kw.net.lookupHost('example.com').size() == 0)",
+	} {
+		ast, issues := env.Compile(expression)
+		require.Empty(t, issues)
+
+		prog, err := env.Program(ast)
+		require.NoError(t, err)
+
+		val, _, err := prog.Eval(map[string]interface{}{})
+		require.NoError(t, err)
+		require.Equal(t, true, val.Value())
+	}
+
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 1)
+}
+
+// setHostClient replaces the host client for the duration of the test.
+func setHostClient(t *testing.T, client capabilities.WapcClient) {
+	t.Helper()
+
+	previous := SetHostClient(client)
+	t.Cleanup(func() { SetHostClient(previous) })
+}
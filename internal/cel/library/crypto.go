@@ -2,11 +2,20 @@
 package library
 
 import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	crypto "github.com/kubewarden/cel-policy/internal/cel/library/kw.crypto"
 	cryptoCap "github.com/kubewarden/policy-sdk-go/pkg/capabilities/crypto"
 )
 
@@ -15,26 +24,35 @@ import (
 // certificate
 //
 // Returns a certificate verifier that can be used to verify the trust of the certificate.
+// The certificate can be a PEM string, PEM or DER bytes, e.g. the bytes returned by `base64.decode`,
+// or a base64 string of PEM or DER data, like the `caBundle` of a webhook or the data of a Secret.
+// When there are many certificates, like in a bundle, the first one is verified and the others
+// are added to the certificate chain.
 //
 //	kw.crypto.certificate(<string>) <CryptoVerifier>
+//	kw.crypto.certificate(<bytes>) <CryptoVerifier>
 //
 // Examples:
 //
 //	kw.crypto.certificate('PEM CERTIFICATE') // returns a certificate verifier for the given PEM encoded certificate
+//	kw.crypto.certificate(object.data['tls.crt']) // returns a certificate verifier for the base64 encoded certificate, with its chain
 //
 // certificateChain
 //
-// Adds a certificate to the certificate chain.
+// Adds a certificate to the certificate chain, given in the same formats as `certificate`.
+// All the certificates of a bundle are added to the chain, in their order.
 //
 //	<CryptoVerifier>.certificateChain(<string>) <CryptoVerifier>
+//	<CryptoVerifier>.certificateChain(<bytes>) <CryptoVerifier>
 //
 // Examples:
 //
 //	kw.crypto.certificate('PEM CERTIFICATE').certificateChain('PEM CERTIFICATE') // returns a certificate verifier with the given PEM encoded certificate added to the chain
+//	kw.crypto.certificate('PEM CERTIFICATE').certificateChain(base64.decode(object.webhooks[0].clientConfig.caBundle)) // returns a certificate verifier with the certificates of the CA bundle added to the chain
 //
 // notAfter
 //
-// Sets the not after date for the certificate verification. If `notAfter` is not set, the certificate is assumed to never expire.
+// Sets the not after date for the certificate verification. If `notAfter` is not set, the certificate is verified at the current time.
 // The date must be a `google.protobuf.Timestamp`.
 // A `google.protobuf.Timestamp` can be created using the `timestamp` standard definition, by passing a string in RFC 3339 format.
 // See: https://github.com/google/cel-spec/blob/master/doc/langdef.md#list-of-standard-definitions
@@ -57,6 +75,37 @@ import (
 // Examples:
 //
 //	kw.crypto.certificate('PEM CERTIFICATE').certificateChain('PEM CERTIFICATE').notAfter(timestamp('2000-01-01T00:00:00Z')).verify().isTrusted() // returns true if the certificate is trusted
+//
+// parseCertificate
+//
+// Parses the first certificate of a certificate or certificate bundle, without calling the host.
+// The certificate is given in the same formats as to `certificate`: a PEM string, PEM or DER bytes,
+// or a base64 string of PEM or DER data.
+// For a certificate chain, like the `tls.crt` of a `kubernetes.io/tls` Secret, the first certificate is the leaf certificate.
+// Returns a `kw.crypto.Certificate` object with the fields:
+// `subject` and `issuer`, `kw.crypto.Name` objects with the fields `commonName`, `organization`, `organizationalUnit`, `country`, `province`, `locality` and `distinguishedName`;
+// `serialNumber`, `notBefore`, `notAfter`, `dnsNames`, `ipAddresses`, `emailAddresses`, `uris`, `isCA`,
+// `keyAlgorithm` (e.g. `RSA`, `ECDSA`, `Ed25519`), `keySize` in bits, `signatureAlgorithm` (e.g. `SHA256-RSA`),
+// `keyUsages` (e.g. `digitalSignature`, `keyCertSign`) and `extKeyUsages` (e.g. `serverAuth`, `clientAuth`).
+//
+//	kw.crypto.parseCertificate(<string>) <Certificate>
+//	kw.crypto.parseCertificate(<bytes>) <Certificate>
+//
+// Examples:
+//
+//	kw.crypto.parseCertificate(object.data['tls.crt']).dnsNames // returns the DNS names of the certificate of a TLS Secret
+//	kw.crypto.parseCertificate(base64.decode(object.data['tls.crt'])).keySize >= 2048 // returns true if the key of the certificate has at least 2048 bits
+//
+// parseCertificates
+//
+// Parses all the certificates of a certificate bundle, given in the same formats as to `parseCertificate`, without calling the host.
+//
+//	kw.crypto.parseCertificates(<string>) <list<Certificate>>
+//	kw.crypto.parseCertificates(<bytes>) <list<Certificate>>
+//
+// Examples:
+//
+//	kw.crypto.parseCertificates(object.webhooks[0].clientConfig.caBundle).all(c, c.isCA) // returns true if all the certificates of the CA bundle are CA certificates
 func Crypto() cel.EnvOption {
 	return cel.Lib(cryptoLib{})
 }
@@ -69,12 +118,18 @@ func (cryptoLib) LibraryName() string {
 
 func (cryptoLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
+		cryptoNativeTypes(),
 		cel.Function("kw.crypto.certificate",
 			cel.Overload("kw_crypto_certifcate",
 				[]*cel.Type{cel.StringType},
 				cryptoVerifierType,
 				cel.UnaryBinding(cryptoCertificate),
 			),
+			cel.Overload("kw_crypto_certifcate_bytes",
+				[]*cel.Type{cel.BytesType},
+				cryptoVerifierType,
+				cel.UnaryBinding(cryptoCertificate),
+			),
 		),
 		cel.Function("certificateChain",
 			cel.MemberOverload("kw_crypto_verifier_certificate_chain",
@@ -82,6 +137,11 @@ func (cryptoLib) CompileOptions() []cel.EnvOption {
 				cryptoVerifierType,
 				cel.BinaryBinding(cryptoVerifierCertificateChain),
 			),
+			cel.MemberOverload("kw_crypto_verifier_certificate_chain_bytes",
+				[]*cel.Type{cryptoVerifierType, cel.BytesType},
+				cryptoVerifierType,
+				cel.BinaryBinding(cryptoVerifierCertificateChain),
+			),
 		),
 		cel.Function("notAfter",
 			cel.MemberOverload("kw_crypto_verifier_not_after",
@@ -104,6 +164,30 @@ func (cryptoLib) CompileOptions() []cel.EnvOption {
 				cel.UnaryBinding(cryptoResponseIsTrusted),
 			),
 		),
+		cel.Function("kw.crypto.parseCertificate",
+			cel.Overload("kw_crypto_parse_certificate_string",
+				[]*cel.Type{cel.StringType},
+				cryptoCertificateType,
+				cel.UnaryBinding(cryptoParseCertificate),
+			),
+			cel.Overload("kw_crypto_parse_certificate_bytes",
+				[]*cel.Type{cel.BytesType},
+				cryptoCertificateType,
+				cel.UnaryBinding(cryptoParseCertificate),
+			),
+		),
+		cel.Function("kw.crypto.parseCertificates",
+			cel.Overload("kw_crypto_parse_certificates_string",
+				[]*cel.Type{cel.StringType},
+				cel.ListType(cryptoCertificateType),
+				cel.UnaryBinding(cryptoParseCertificates),
+			),
+			cel.Overload("kw_crypto_parse_certificates_bytes",
+				[]*cel.Type{cel.BytesType},
+				cel.ListType(cryptoCertificateType),
+				cel.UnaryBinding(cryptoParseCertificates),
+			),
+		),
 		cel.Function("reason",
 			cel.MemberOverload("kw_crypto_response_reason",
 				[]*cel.Type{cryptoResponseType},
@@ -119,15 +203,24 @@ func (cryptoLib) ProgramOptions() []cel.ProgramOption {
 }
 
 func cryptoCertificate(arg ref.Val) ref.Val {
-	certifcate, ok := arg.Value().(string)
-	if !ok {
+	certificates, err := hostCertificates(arg)
+	if err != nil {
+		return types.NewErr("invalid certificate: %s", err)
+	}
+	if certificates == nil {
 		return types.MaybeNoSuchOverloadErr(arg)
 	}
 
-	return cryptoVerifier{
+	verifier := cryptoVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(cryptoVerifierType),
-		certifcate:            cryptoCap.Certificate{Encoding: cryptoCap.Pem, Data: []rune(certifcate)},
+		certifcate:            certificates[0],
+	}
+	// the other certificates of a bundle are the chain
+	if len(certificates) > 1 {
+		verifier.certifcateChain = certificates[1:]
 	}
+
+	return verifier
 }
 
 func cryptoVerifierCertificateChain(arg1, arg2 ref.Val) ref.Val {
@@ -136,16 +229,131 @@ func cryptoVerifierCertificateChain(arg1, arg2 ref.Val) ref.Val {
 		return types.MaybeNoSuchOverloadErr(arg1)
 	}
 
-	certifcate, ok := arg2.Value().(string)
-	if !ok {
+	certificates, err := hostCertificates(arg2)
+	if err != nil {
+		return types.NewErr("invalid certificate chain: %s", err)
+	}
+	if certificates == nil {
 		return types.MaybeNoSuchOverloadErr(arg2)
 	}
 
-	verifier.certifcateChain = append(verifier.certifcateChain, cryptoCap.Certificate{Encoding: cryptoCap.Pem, Data: []rune(certifcate)})
+	// the chain is shared with the verifiers built from the same certificate
+	verifier.certifcateChain = append(slices.Clone(verifier.certifcateChain), certificates...)
 
 	return verifier
 }
 
+// hostCertificates returns the certificates of the string or bytes value, as
+// sent to the host, see decodeCertificates. Data that is not recognized is sent
+// as is, the host reports whether it is a valid certificate. It returns nil if
+// the value is neither a string nor bytes.
+func hostCertificates(arg ref.Val) ([]cryptoCap.Certificate, error) {
+	data, ok := certificateData(arg)
+	if !ok {
+		return nil, nil
+	}
+
+	isPEMData := isPEM(string(data))
+	ders, err := decodeCertificates(data)
+	if err != nil {
+		if isPEMData {
+			return nil, err
+		}
+		// a string that is neither PEM nor base64
+		if value, ok := arg.(types.String); ok && string(value) == string(data) {
+			return []cryptoCap.Certificate{{Encoding: cryptoCap.Pem, Data: []rune(string(data))}}, nil
+		}
+
+		return []cryptoCap.Certificate{derCertificate(data)}, nil
+	}
+
+	certificates := make([]cryptoCap.Certificate, 0, len(ders))
+	for _, der := range ders {
+		if isPEMData {
+			pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+			certificates = append(certificates, cryptoCap.Certificate{Encoding: cryptoCap.Pem, Data: []rune(string(pemData))})
+		} else {
+			certificates = append(certificates, derCertificate(der))
+		}
+	}
+
+	return certificates, nil
+}
+
+// certificateData returns the data of the certificates of the string or bytes
+// value. A string that is not PEM is decoded as base64, like the `caBundle` of a
+// webhook or the data of a Secret, and kept as is if it is not base64.
+// It returns false if the value is neither a string nor bytes.
+func certificateData(arg ref.Val) ([]byte, bool) {
+	switch value := arg.(type) {
+	case types.String:
+		if !isPEM(string(value)) {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+			if err == nil {
+				return decoded, true
+			}
+		}
+
+		return []byte(value), true
+	case types.Bytes:
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// decodeCertificates returns the DER encoded certificates of the PEM or DER data.
+// A PEM bundle is split into its certificates, ignoring the blocks that are not
+// certificates, like private keys, and so are concatenated DER certificates.
+func decodeCertificates(data []byte) ([][]byte, error) {
+	if !isPEM(string(data)) {
+		parsed, err := x509.ParseCertificates(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed) == 0 {
+			return nil, errors.New("no certificate found")
+		}
+
+		ders := make([][]byte, 0, len(parsed))
+		for _, certificate := range parsed {
+			ders = append(ders, certificate.Raw)
+		}
+
+		return ders, nil
+	}
+
+	var ders [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ders = append(ders, block.Bytes)
+	}
+
+	if len(ders) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	return ders, nil
+}
+
+// derCertificate returns the DER certificate sent to the host, whose data is
+// the list of the bytes of the certificate.
+func derCertificate(der []byte) cryptoCap.Certificate {
+	data := make([]rune, 0, len(der))
+	for _, b := range der {
+		data = append(data, rune(b))
+	}
+
+	return cryptoCap.Certificate{Encoding: cryptoCap.Der, Data: data}
+}
+
 func cryptoVerifierNotAfter(arg1, arg2 ref.Val) ref.Val {
 	verifier, ok := arg1.(cryptoVerifier)
 
@@ -170,7 +378,12 @@ func cryptoVerifierVerify(arg ref.Val) ref.Val {
 		return types.MaybeNoSuchOverloadErr(arg)
 	}
 
-	response, err := cryptoCap.VerifyCert(&host, verifier.certifcate, verifier.certifcateChain, verifier.notAfter.Format(time.RFC3339))
+	notAfter := now()
+	if verifier.notAfter != nil {
+		notAfter = *verifier.notAfter
+	}
+
+	response, err := cryptoCap.VerifyCert(&host, verifier.certifcate, verifier.certifcateChain, notAfter.UTC().Format(time.RFC3339))
 	if err != nil {
 		return types.NewErr("%v", err.Error())
 	}
@@ -202,6 +415,76 @@ func cryptoResponseReason(arg ref.Val) ref.Val {
 	return types.String(response.reason)
 }
 
+func cryptoParseCertificate(arg ref.Val) ref.Val {
+	certificates, err := parseCertificates(arg)
+	if err != nil {
+		return types.NewErr("cannot parse certificate: %s", err)
+	}
+	if certificates == nil {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return nativeValue(crypto.NewCertificate(certificates[0]))
+}
+
+func cryptoParseCertificates(arg ref.Val) ref.Val {
+	certificates, err := parseCertificates(arg)
+	if err != nil {
+		return types.NewErr("cannot parse certificates: %s", err)
+	}
+	if certificates == nil {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	result := make([]ref.Val, 0, len(certificates))
+	for _, certificate := range certificates {
+		result = append(result, nativeValue(crypto.NewCertificate(certificate)))
+	}
+
+	return types.NewRefValList(types.DefaultTypeAdapter, result)
+}
+
+// parseCertificates parses the certificates of the string or bytes value, given
+// in the same formats as to the host, see certificateData and decodeCertificates.
+// It returns nil if the value is neither a string nor bytes.
+func parseCertificates(arg ref.Val) ([]*x509.Certificate, error) {
+	data, ok := certificateData(arg)
+	if !ok {
+		return nil, nil
+	}
+
+	ders, err := decodeCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
+
+var cryptoCertificateType = cel.ObjectType("kw.crypto.Certificate")
+
+// cryptoNativeTypes declares the X.509 objects, and the objects of their fields, as CEL types.
+func cryptoNativeTypes() cel.EnvOption {
+	return ext.NativeTypes(
+		ext.ParseStructTag("json"),
+		reflect.TypeOf(&crypto.Certificate{}),
+	)
+}
+
+// now returns the time the certificates are verified at, when `notAfter` is not set.
+//
+//nolint:gochecknoglobals // replaced by the tests
+var now = time.Now
+
 var cryptoVerifierType = cel.ObjectType("kw.crypto.Verifier")
 
 type cryptoVerifier struct {
@@ -0,0 +1,285 @@
+//nolint:lll // This file has long lines due some examples in the comments
+package library
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // SHA-1 is provided to compare existing checksums, not to sign data
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Hash provides a CEL function library extension for hashing data.
+// The functions are evaluated by the policy, without calling the host.
+// The data and the keys can be strings or bytes, e.g. the bytes returned by `base64.decode`.
+// Two digests are equal when their bytes are equal, e.g. `kw.hash.sha256(a) == kw.hash.sha256(b)`.
+//
+// sha256
+//
+// Returns the SHA-256 digest of the data.
+//
+//	kw.hash.sha256(<string>) <Digest>
+//	kw.hash.sha256(<bytes>) <Digest>
+//
+// Examples:
+//
+//	kw.hash.sha256('hello').hex() // returns '2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824'
+//
+// sha512
+//
+// Returns the SHA-512 digest of the data.
+//
+//	kw.hash.sha512(<string>) <Digest>
+//	kw.hash.sha512(<bytes>) <Digest>
+//
+// Examples:
+//
+//	kw.hash.sha512(base64.decode(object.data['config'])).hex() // returns the hex encoded SHA-512 digest of the decoded data
+//
+// sha1
+//
+// Returns the SHA-1 digest of the data.
+// SHA-1 is not collision resistant, it should only be used to compare existing checksums.
+//
+//	kw.hash.sha1(<string>) <Digest>
+//	kw.hash.sha1(<bytes>) <Digest>
+//
+// Examples:
+//
+//	kw.hash.sha1('hello').hex() // returns 'aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d'
+//
+// hmacSha256
+//
+// Returns the HMAC-SHA256 of the data, computed with the given key.
+//
+//	kw.hash.hmacSha256(<string>, <string>) <Digest>
+//	kw.hash.hmacSha256(<bytes>, <bytes>) <Digest>
+//	kw.hash.hmacSha256(<string>, <bytes>) <Digest>
+//	kw.hash.hmacSha256(<bytes>, <string>) <Digest>
+//
+// Examples:
+//
+//	kw.hash.hmacSha256('key', 'hello').hex() // returns the hex encoded HMAC-SHA256 of 'hello' with the key 'key'
+//
+// hex
+//
+// Returns the digest encoded as a lowercase hexadecimal string.
+//
+//	<Digest>.hex() <string>
+//
+// Examples:
+//
+//	kw.hash.sha256(object.metadata.name).hex().substring(0, 8) // returns a stable 8 characters suffix derived from the name
+//
+// base64
+//
+// Returns the digest encoded as a standard base64 string, with padding.
+//
+//	<Digest>.base64() <string>
+//
+// Examples:
+//
+//	kw.hash.sha256('hello').base64() // returns 'LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ='
+//
+// bytes
+//
+// Returns the digest as bytes, e.g. to encode it with the `base64` functions.
+//
+//	<Digest>.bytes() <bytes>
+//
+// Examples:
+//
+//	base64.encode(kw.hash.sha256('hello').bytes()) // returns 'LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ='
+func Hash() cel.EnvOption {
+	return cel.Lib(hashLib{})
+}
+
+type hashLib struct{}
+
+func (hashLib) LibraryName() string {
+	return "kw.hash"
+}
+
+func (hashLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("kw.hash.sha256",
+			cel.Overload("kw_hash_sha256_string",
+				[]*cel.Type{cel.StringType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha256.New)),
+			),
+			cel.Overload("kw_hash_sha256_bytes",
+				[]*cel.Type{cel.BytesType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha256.New)),
+			),
+		),
+		cel.Function("kw.hash.sha512",
+			cel.Overload("kw_hash_sha512_string",
+				[]*cel.Type{cel.StringType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha512.New)),
+			),
+			cel.Overload("kw_hash_sha512_bytes",
+				[]*cel.Type{cel.BytesType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha512.New)),
+			),
+		),
+		cel.Function("kw.hash.sha1",
+			cel.Overload("kw_hash_sha1_string",
+				[]*cel.Type{cel.StringType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha1.New)),
+			),
+			cel.Overload("kw_hash_sha1_bytes",
+				[]*cel.Type{cel.BytesType},
+				hashDigestType,
+				cel.UnaryBinding(hashFunction(sha1.New)),
+			),
+		),
+		cel.Function("kw.hash.hmacSha256",
+			cel.Overload("kw_hash_hmac_sha256_string_string",
+				[]*cel.Type{cel.StringType, cel.StringType},
+				hashDigestType,
+				cel.BinaryBinding(hashHmacSha256),
+			),
+			cel.Overload("kw_hash_hmac_sha256_bytes_bytes",
+				[]*cel.Type{cel.BytesType, cel.BytesType},
+				hashDigestType,
+				cel.BinaryBinding(hashHmacSha256),
+			),
+			cel.Overload("kw_hash_hmac_sha256_string_bytes",
+				[]*cel.Type{cel.StringType, cel.BytesType},
+				hashDigestType,
+				cel.BinaryBinding(hashHmacSha256),
+			),
+			cel.Overload("kw_hash_hmac_sha256_bytes_string",
+				[]*cel.Type{cel.BytesType, cel.StringType},
+				hashDigestType,
+				cel.BinaryBinding(hashHmacSha256),
+			),
+		),
+		cel.Function("hex",
+			cel.MemberOverload("kw_hash_digest_hex",
+				[]*cel.Type{hashDigestType},
+				cel.StringType,
+				cel.UnaryBinding(hashDigestHex),
+			),
+		),
+		cel.Function("base64",
+			cel.MemberOverload("kw_hash_digest_base64",
+				[]*cel.Type{hashDigestType},
+				cel.StringType,
+				cel.UnaryBinding(hashDigestBase64),
+			),
+		),
+		cel.Function("bytes",
+			cel.MemberOverload("kw_hash_digest_bytes",
+				[]*cel.Type{hashDigestType},
+				cel.BytesType,
+				cel.UnaryBinding(hashDigestBytes),
+			),
+		),
+	}
+}
+
+func (hashLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{}
+}
+
+// hashFunction returns the binding computing the digest of the data with the given hash function.
+func hashFunction(newHash func() hash.Hash) func(ref.Val) ref.Val {
+	return func(arg ref.Val) ref.Val {
+		data, ok := hashData(arg)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(arg)
+		}
+
+		h := newHash()
+		h.Write(data)
+
+		return hashDigest{receiverOnlyObjectVal: receiverOnlyVal(hashDigestType), digest: h.Sum(nil)}
+	}
+}
+
+func hashHmacSha256(arg1, arg2 ref.Val) ref.Val {
+	key, ok := hashData(arg1)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	data, ok := hashData(arg2)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hashDigest{receiverOnlyObjectVal: receiverOnlyVal(hashDigestType), digest: mac.Sum(nil)}
+}
+
+// hashData returns the bytes of a string or bytes value.
+func hashData(arg ref.Val) ([]byte, bool) {
+	switch value := arg.(type) {
+	case types.String:
+		return []byte(value), true
+	case types.Bytes:
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+func hashDigestHex(arg ref.Val) ref.Val {
+	digest, ok := arg.(hashDigest)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.String(hex.EncodeToString(digest.digest))
+}
+
+func hashDigestBase64(arg ref.Val) ref.Val {
+	digest, ok := arg.(hashDigest)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.String(base64.StdEncoding.EncodeToString(digest.digest))
+}
+
+func hashDigestBytes(arg ref.Val) ref.Val {
+	digest, ok := arg.(hashDigest)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.Bytes(digest.digest)
+}
+
+var hashDigestType = cel.ObjectType("kw.hash.Digest")
+
+// hashDigest is the digest returned by the hash functions.
+type hashDigest struct {
+	receiverOnlyObjectVal
+	digest []byte
+}
+
+// Equal implements ref.Val.Equal, the digests are compared by value.
+func (d hashDigest) Equal(other ref.Val) ref.Val {
+	o, ok := other.(hashDigest)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(other)
+	}
+
+	return types.Bool(bytes.Equal(d.digest, o.digest))
+}
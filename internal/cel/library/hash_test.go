@@ -0,0 +1,55 @@
+package library
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name           string
+		expression     string
+		expectedResult any
+	}{
+		{"sha256 hex", "kw.hash.sha256('hello').hex()", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"sha256 base64", "kw.hash.sha256('hello').base64()", "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="},
+		{"sha256 bytes", "base64.encode(kw.hash.sha256(b'hello').bytes())", "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="},
+		{"sha256 of decoded data", "kw.hash.sha256(base64.decode('aGVsbG8=')).hex() == kw.hash.sha256('hello').hex()", true},
+		{"sha512 hex", "kw.hash.sha512('hello').hex()", "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"},
+		{"sha1 hex", "kw.hash.sha1(b'hello').hex()", "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{"hmacSha256", "kw.hash.hmacSha256('key', 'The quick brown fox jumps over the lazy dog').hex()", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"hmacSha256 with bytes", "kw.hash.hmacSha256(b'key', b'The quick brown fox jumps over the lazy dog').hex() == kw.hash.hmacSha256(b'key', 'The quick brown fox jumps over the lazy dog').hex()", true},
+		{"bytes conversion", "bytes('hello') == b'hello'", true},
+		{"equal digests", "kw.hash.sha256('hello') == kw.hash.sha256(b'hello')", true},
+		{"different digests", "kw.hash.sha256('hello') == kw.hash.sha256('world')", false},
+		{"digests in a list", "kw.hash.sha256('hello') in [kw.hash.sha256('world'), kw.hash.sha256(base64.decode('aGVsbG8='))]", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env, err := cel.NewEnv(
+				Hash(),
+				ext.Encoders(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+}
@@ -3,10 +3,12 @@ package library
 
 import (
 	"encoding/json"
+	"regexp"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
 )
 
@@ -51,10 +53,19 @@ import (
 // NOTE: this is ignored for get operations. The label selector should be a valid Kubernetes label selector.
 //
 //	<Client>.labelSelector(<string>) <Client>
+//	<Client>.labelSelector(<map<string,string>>) <Client>
+//	<Client>.labelSelector(<LabelSelector>) <Client>
+//
+// The label selector can be given as a string, as a map of labels, the same as `matchLabels`,
+// or as a [`LabelSelector`](https://pkg.go.dev/k8s.io/apimachinery/pkg/apis/meta/v1#LabelSelector) object,
+// having the `matchLabels` and `matchExpressions` fields.
+// Label selector string literals are validated when the expression is compiled.
 //
 // Examples:
 //
 //	kw.k8s.apiVersion('v1').kind('Pod').labelSelector('app=nginx') // returns a Client for the 'Pod' resources in the core group with the label selector 'app=nginx'
+//	kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'app': 'nginx'}) // returns a Client for the 'Pod' resources in the core group with the label selector 'app=nginx'
+//	kw.k8s.apiVersion('v1').kind('Pod').labelSelector(object.spec.selector) // returns a Client for the 'Pod' resources selected by the Deployment being validated
 //
 // fieldSelector
 //
@@ -67,6 +78,21 @@ import (
 //
 //	kw.k8s.apiVersion('v1').kind('Pod').fieldSelector('status.phase=Running') // returns a Client for the 'Pod' resources in the core group with the field selector 'status.phase=Running'
 //
+// fresh
+//
+// Returns a client that bypasses the caches when getting or listing resources.
+// The `get` calls disable the cache of the policy host, which may return data up to 5 seconds old,
+// while both `get` and `list` calls bypass the cache of the responses memoized while evaluating the request.
+// NOTE: the policy host has no cache control for list operations, those may still return cached data.
+// Fresh reads put more load on the Kubernetes API server: avoid them within comprehensions, where they are
+// done once per element.
+//
+//	<Client>.fresh() <Client>
+//
+// Examples:
+//
+//	kw.k8s.apiVersion('rbac.authorization.k8s.io/v1').kind('RoleBinding').namespace('default').fresh().has('admins') // returns true if the 'admins' RoleBinding is still present in the 'default' namespace
+//
 // list
 //
 // Returns a list of Kubernetes resources matching the client configuration.
@@ -94,6 +120,65 @@ import (
 //	kw.k8s.apiVersion('v1').kind('Pod').namespace('default').get('nginx') // returns the 'Pod' resource with the name 'nginx' in the 'default' namespace
 //	kw.k8s.apiVersion('v1').kind('Pod').get('nginx') // error, 'Pod' resources are namespaced and the namespace must be set
 //	kw.k8s.apiVersion('v1').kind('Namespace').get('default') // returns the 'Namespace' resource with the name 'default'
+//
+// getOptional
+//
+// Returns the Kubernetes resource matching the provided name as an optional value, which is empty when the resource does not exist.
+// Errors other than the resource not being found are returned as errors, the same as with get.
+//
+//	<Client>.getOptional(<string>) <optional<object>>
+//
+// Examples:
+//
+//	kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').getOptional('config').hasValue() // returns true if the 'config' ConfigMap exists in the 'default' namespace
+//	kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').getOptional('config').?data.mode.orValue('') == 'strict' // checks the ConfigMap data, if the ConfigMap exists
+//
+// has
+//
+// Returns true if the Kubernetes resource matching the provided name exists.
+// Errors other than the resource not being found are returned as errors.
+// NOTE: the function cannot be named `exists`, since it would clash with the `exists` macro.
+//
+//	<Client>.has(<string>) <bool>
+//
+// Examples:
+//
+//	kw.k8s.apiVersion('v1').kind('Secret').namespace('default').has('tls') // returns true if the 'tls' Secret exists in the 'default' namespace
+//
+// namespaceObject
+//
+// Returns the Namespace resource with the provided name.
+// Namespaces are cached by name while evaluating a request, and they are shared with the `namespaceObject` variable.
+//
+//	kw.k8s.namespaceObject(<string>) <object>
+//
+// Examples:
+//
+//	kw.k8s.namespaceObject('kube-system').metadata.labels // returns the labels of the 'kube-system' namespace
+//
+// owners
+//
+// Returns the chain of the controllers of the provided object, from its direct controller up to the top-level one.
+// The owner references having `controller: true` are followed, looking up the owners in the namespace of the object,
+// or as cluster-scoped resources when they are not found there, like the Node controlling a mirror Pod.
+// The chain is limited to 10 owners, and cyclic owner references are returned as errors.
+//
+//	kw.k8s.owners(<object>) <list<object>>
+//
+// Examples:
+//
+//	kw.k8s.owners(object) // returns the ReplicaSet and the Deployment controlling a Pod
+//	kw.k8s.owners(object).exists(o, o.kind == 'CronJob') // returns true if the object is controlled by a CronJob
+//
+// rootOwner
+//
+// Returns the top-level controller of the provided object, or the object itself when it has no controller.
+//
+//	kw.k8s.rootOwner(<object>) <object>
+//
+// Examples:
+//
+//	kw.k8s.rootOwner(object).metadata.labels // returns the labels of the Deployment controlling a Pod
 func Kubernetes() cel.EnvOption {
 	return cel.Lib(&kubernetesLib{})
 }
@@ -106,6 +191,7 @@ func (*kubernetesLib) LibraryName() string {
 
 func (*kubernetesLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
+		cel.ASTValidators(labelSelectorValidator{}),
 		cel.Function("kw.k8s.apiVersion",
 			cel.Overload("kw_k8s_api_version",
 				[]*cel.Type{cel.StringType},
@@ -133,6 +219,11 @@ func (*kubernetesLib) CompileOptions() []cel.EnvOption {
 				k8sClientType,
 				cel.BinaryBinding(k8sClientLabelSelector),
 			),
+			cel.MemberOverload("kw_k8s_label_selector_map",
+				[]*cel.Type{k8sClientType, cel.MapType(cel.StringType, cel.DynType)},
+				k8sClientType,
+				cel.BinaryBinding(k8sClientLabelSelectorMap),
+			),
 		),
 		cel.Function("fieldSelector",
 			cel.MemberOverload("kw_k8s_field_selector",
@@ -141,6 +232,13 @@ func (*kubernetesLib) CompileOptions() []cel.EnvOption {
 				cel.BinaryBinding(k8sClientFieldSelector),
 			),
 		),
+		cel.Function("fresh",
+			cel.MemberOverload("kw_k8s_fresh",
+				[]*cel.Type{k8sClientType},
+				k8sClientType,
+				cel.UnaryBinding(k8sClientFresh),
+			),
+		),
 		cel.Function("list",
 			cel.MemberOverload("kw_k8s_list",
 				[]*cel.Type{k8sClientType},
@@ -155,6 +253,41 @@ func (*kubernetesLib) CompileOptions() []cel.EnvOption {
 				cel.BinaryBinding(k8sClientGet),
 			),
 		),
+		cel.Function("getOptional",
+			cel.MemberOverload("kw_k8s_get_optional",
+				[]*cel.Type{k8sClientType, cel.StringType},
+				cel.OptionalType(cel.DynType),
+				cel.BinaryBinding(k8sClientGetOptional),
+			),
+		),
+		cel.Function("has",
+			cel.MemberOverload("kw_k8s_has",
+				[]*cel.Type{k8sClientType, cel.StringType},
+				cel.BoolType,
+				cel.BinaryBinding(k8sClientHas),
+			),
+		),
+		cel.Function("kw.k8s.namespaceObject",
+			cel.Overload("kw_k8s_namespace_object",
+				[]*cel.Type{cel.StringType},
+				cel.DynType,
+				cel.UnaryBinding(namespaceObject),
+			),
+		),
+		cel.Function("kw.k8s.owners",
+			cel.Overload("kw_k8s_owners",
+				[]*cel.Type{cel.DynType},
+				cel.ListType(cel.DynType),
+				cel.UnaryBinding(owners),
+			),
+		),
+		cel.Function("kw.k8s.rootOwner",
+			cel.Overload("kw_k8s_root_owner",
+				[]*cel.Type{cel.DynType},
+				cel.DynType,
+				cel.UnaryBinding(rootOwner),
+			),
+		),
 	}
 }
 
@@ -233,6 +366,17 @@ func k8sClientFieldSelector(arg1, arg2 ref.Val) ref.Val {
 	return client
 }
 
+func k8sClientFresh(arg ref.Val) ref.Val {
+	client, ok := arg.(k8sClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	client.fresh = true
+
+	return client
+}
+
 func k8sClientList(arg ref.Val) ref.Val {
 	client, ok := arg.(k8sClient)
 	if !ok {
@@ -256,6 +400,87 @@ func k8sClientGet(arg1 ref.Val, arg2 ref.Val) ref.Val {
 	return client.get(name)
 }
 
+func k8sClientGetOptional(arg1 ref.Val, arg2 ref.Val) ref.Val {
+	client, ok := arg1.(k8sClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	name, ok := arg2.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	return client.getOptional(name)
+}
+
+func k8sClientHas(arg1 ref.Val, arg2 ref.Val) ref.Val {
+	client, ok := arg1.(k8sClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	name, ok := arg2.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	result := client.getOptional(name)
+	if types.IsError(result) {
+		return result
+	}
+
+	optional, ok := result.(*types.Optional)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(result)
+	}
+
+	return types.Bool(optional.HasValue())
+}
+
+func namespaceObject(arg ref.Val) ref.Val {
+	name, ok := arg.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return NamespaceObject(name)
+}
+
+// NamespaceObject returns the Namespace resource with the given name.
+// The namespaces, and the errors fetching them, are cached by name until the
+// host cache is reset, so that a namespace is fetched once per request.
+func NamespaceObject(name string) ref.Val {
+	if val, found := namespaces[name]; found {
+		return val
+	}
+
+	val := getNamespace(name)
+	namespaces[name] = val
+
+	return val
+}
+
+func getNamespace(name string) ref.Val {
+	request := kubernetes.GetResourceRequest{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       name,
+	}
+
+	responseBytes, err := kubernetes.GetResource(&host, request)
+	if err != nil {
+		return types.NewErr("cannot get namespace data: %s", err)
+	}
+
+	var response map[string]interface{}
+	if err = json.Unmarshal(responseBytes, &response); err != nil {
+		return types.NewErr("cannot parse namespace data: %s", err)
+	}
+
+	return types.NewDynamicMap(types.DefaultTypeAdapter, response)
+}
+
 var k8sClientBuilderType = cel.ObjectType("kw.k8s.ClientBuilder")
 
 // k8sClientBuilder is an intermediate object that holds the API version.
@@ -276,6 +501,8 @@ type k8sClient struct {
 	namespace     *string
 	labelSelector *string
 	fieldSelector *string
+	// fresh bypasses the caches of the policy host and of the request.
+	fresh bool
 }
 
 // list returns a list of Kubernetes resources.
@@ -291,7 +518,7 @@ func (c *k8sClient) list() ref.Val {
 			FieldSelector: c.fieldSelector,
 		}
 
-		responseBytes, err = kubernetes.ListResourcesByNamespace(&host, request)
+		responseBytes, err = kubernetes.ListResourcesByNamespace(c.host(), request)
 	} else {
 		request := kubernetes.ListAllResourcesRequest{
 			APIVersion:    c.apiVersion,
@@ -300,7 +527,7 @@ func (c *k8sClient) list() ref.Val {
 			FieldSelector: c.fieldSelector,
 		}
 
-		responseBytes, err = kubernetes.ListResources(&host, request)
+		responseBytes, err = kubernetes.ListResources(c.host(), request)
 	}
 
 	if err != nil {
@@ -317,22 +544,70 @@ func (c *k8sClient) list() ref.Val {
 
 // get returns a Kubernetes resource.
 func (c *k8sClient) get(name string) ref.Val {
-	request := kubernetes.GetResourceRequest{
-		APIVersion: c.apiVersion,
-		Kind:       c.kind,
-		Name:       name,
-		Namespace:  c.namespace,
+	responseBytes, err := c.getResource(name)
+	if err != nil {
+		return types.NewErr("cannot get Kubernetes resource: %s", err)
 	}
 
-	responseBytes, err := kubernetes.GetResource(&host, request)
+	return unmarshalResource(responseBytes)
+}
+
+// getOptional returns a Kubernetes resource as an optional value, which is
+// empty when the resource is not found.
+func (c *k8sClient) getOptional(name string) ref.Val {
+	responseBytes, err := c.getResource(name)
 	if err != nil {
+		if isNotFoundError(err) {
+			return types.OptionalNone
+		}
+
 		return types.NewErr("cannot get Kubernetes resource: %s", err)
 	}
 
+	resource := unmarshalResource(responseBytes)
+	if types.IsError(resource) {
+		return resource
+	}
+
+	return types.OptionalOf(resource)
+}
+
+func (c *k8sClient) getResource(name string) ([]byte, error) {
+	request := kubernetes.GetResourceRequest{
+		APIVersion:   c.apiVersion,
+		Kind:         c.kind,
+		Name:         name,
+		Namespace:    c.namespace,
+		DisableCache: c.fresh,
+	}
+
+	return kubernetes.GetResource(c.host(), request)
+}
+
+// host returns the host used by the client, fresh clients bypass the request-scoped cache.
+func (c *k8sClient) host() *capabilities.Host {
+	if c.fresh {
+		return uncachedHost()
+	}
+
+	return &host
+}
+
+func unmarshalResource(responseBytes []byte) ref.Val {
 	var response map[string]interface{}
-	if err = json.Unmarshal(responseBytes, &response); err != nil {
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
 		return types.NewErr("cannot unmarshal Kubernetes get resource response: %s", err)
 	}
 
 	return types.NewDynamicMap(types.DefaultTypeAdapter, response)
 }
+
+// notFoundErrorRegex matches the errors reported by the host when the Kubernetes
+// API server answers that the resource does not exist, e.g.:
+// `configmaps "foo" not found: NotFound (ErrorResponse { status: "Failure", ..., reason: "NotFound", code: 404 })`.
+var notFoundErrorRegex = regexp.MustCompile(`\bNotFound\b|\bcode: 404\b|"[^"]*" not found`)
+
+// isNotFoundError returns true when the host failed because the resource does not exist.
+func isNotFoundError(err error) bool {
+	return notFoundErrorRegex.MatchString(err.Error())
+}
@@ -2,16 +2,20 @@ package library
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/traits"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
 
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/mocks"
 	"github.com/stretchr/testify/require"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestKubernetes(t *testing.T) {
@@ -53,6 +57,50 @@ func TestKubernetes(t *testing.T) {
 			},
 			"app2",
 		},
+		{
+			"list (labels)",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'foo': 'bar'}).list().items[0].metadata.name",
+			"list_resources_all",
+			kubernetes.ListAllResourcesRequest{
+				APIVersion:    "v1",
+				Kind:          "Pod",
+				LabelSelector: stringPtr("foo=bar"),
+			},
+			&corev1.PodList{
+				Items: []*corev1.Pod{
+					{
+						Kind: "Pod",
+						Metadata: &metav1.ObjectMeta{
+							Name:      "app1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			"app1",
+		},
+		{
+			"list (label selector object)",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'matchLabels': {'foo': 'bar'}, 'matchExpressions': [{'key': 'env', 'operator': 'In', 'values': ['prod', 'test']}]}).list().items[0].metadata.name",
+			"list_resources_all",
+			kubernetes.ListAllResourcesRequest{
+				APIVersion:    "v1",
+				Kind:          "Pod",
+				LabelSelector: stringPtr("env in (prod,test),foo=bar"),
+			},
+			&corev1.PodList{
+				Items: []*corev1.Pod{
+					{
+						Kind: "Pod",
+						Metadata: &metav1.ObjectMeta{
+							Name:      "app1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			"app1",
+		},
 		{
 			"list (namespace)",
 			"kw.k8s.apiVersion('v1').kind('Pod').fieldSelector('foo.bar=baz').namespace('default').list().items[0].metadata.name",
@@ -118,7 +166,7 @@ func TestKubernetes(t *testing.T) {
 			mockWapcClient := &mocks.MockWapcClient{}
 			mockWapcClient.On("HostCall", "kubewarden", "kubernetes", test.expectedOperation, expectedRequest).Return(response, nil)
 
-			host.Client = mockWapcClient
+			setHostClient(t, mockWapcClient)
 
 			env, err := cel.NewEnv(
 				Kubernetes(),
@@ -142,6 +190,287 @@ func TestKubernetes(t *testing.T) {
 	}
 }
 
+func TestKubernetesLabelSelectorErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		expression    string
+		expectedError string
+	}{
+		{
+			"invalid string literal",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector('foo==bar,').list()",
+			"invalid label selector",
+		},
+		{
+			"unknown field",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'matchLabels': {'foo': 'bar'}, 'matchLabel': {'foo': 'baz'}}).list()",
+			"invalid label selector: json: unknown field \"matchLabel\"",
+		},
+		{
+			"unknown operator",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'matchExpressions': [{'key': 'env', 'operator': 'Equals', 'values': ['prod']}]}).list()",
+			"invalid label selector: unknown label selector operator",
+		},
+		{
+			"invalid label",
+			"kw.k8s.apiVersion('v1').kind('Pod').labelSelector({'foo/bar/baz': 'bar'}).list()",
+			"invalid label selector: key: Invalid value: \"foo/bar/baz\"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setHostClient(t, &mocks.MockWapcClient{})
+
+			env, err := cel.NewEnv(
+				Kubernetes(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			if issues.Err() != nil {
+				require.ErrorContains(t, issues.Err(), test.expectedError)
+				return
+			}
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			_, _, err = prog.Eval(map[string]interface{}{})
+			require.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestLabelSelectorFromMapNonStringKeys(t *testing.T) {
+	mapper, ok := types.DefaultTypeAdapter.NativeToValue(map[int64]string{1: "bar"}).(traits.Mapper)
+	require.True(t, ok)
+
+	_, err := labelSelectorFromMap(mapper)
+	require.EqualError(t, err, "the keys must be strings, got int")
+}
+
+func TestFormatLabelSelector(t *testing.T) {
+	labelSelector := k8smetav1.LabelSelector{
+		MatchLabels: map[string]string{
+			"app": "my-app",
+		},
+		MatchExpressions: []k8smetav1.LabelSelectorRequirement{
+			{
+				Key:      "environment",
+				Operator: k8smetav1.LabelSelectorOpIn,
+				Values:   []string{"production", "staging"},
+			},
+			{
+				Key:      "phase",
+				Operator: k8smetav1.LabelSelectorOpNotIn,
+				Values:   []string{"initial", "final"},
+			},
+			{
+				Key:      "foo",
+				Operator: k8smetav1.LabelSelectorOpExists,
+				Values:   []string{},
+			},
+			{
+				Key:      "bar",
+				Operator: k8smetav1.LabelSelectorOpDoesNotExist,
+				Values:   []string{},
+			},
+		},
+	}
+
+	labelSelectorString, err := FormatLabelSelector(&labelSelector)
+	require.NoError(t, err)
+	require.Equal(t, "app=my-app,!bar,environment in (production,staging),foo,phase notin (final,initial)", labelSelectorString)
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestKubernetesNamespaceObject(t *testing.T) {
+	mockWapcClient := &mocks.MockWapcClient{}
+	for _, name := range []string{"default", "kube-system"} {
+		request, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: "v1", Kind: "Namespace", Name: name})
+		require.NoError(t, err)
+
+		response, err := json.Marshal(&corev1.Namespace{
+			Metadata: &metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"name": name},
+			},
+		})
+		require.NoError(t, err)
+
+		mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(response, nil)
+	}
+	request, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: "v1", Kind: "Namespace", Name: "missing"})
+	require.NoError(t, err)
+	mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(nil, errors.New("not found"))
+
+	setHostClient(t, mockWapcClient)
+
+	env, err := cel.NewEnv(
+		Kubernetes(),
+	)
+	require.NoError(t, err)
+
+	ast, issues := env.Compile("kw.k8s.namespaceObject('default').metadata.labels.name + ',' + kw.k8s.namespaceObject('kube-system').metadata.labels.name + ',' + kw.k8s.namespaceObject('default').metadata.name")
+	require.Empty(t, issues)
+
+	prog, err := env.Program(ast)
+	require.NoError(t, err)
+
+	val, _, err := prog.Eval(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, "default,kube-system,default", val.Value())
+
+	for range 2 {
+		val := NamespaceObject("missing")
+		require.True(t, types.IsError(val))
+		require.EqualError(t, val.(*types.Err), "cannot get namespace data: not found")
+	}
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 3)
+
+	ResetHostCache()
+	NamespaceObject("default")
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 4)
+}
+
+func TestKubernetesGetOptional(t *testing.T) {
+	configMap, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"name": "config"},
+		"data":     map[string]any{"mode": "strict"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		expression     string
+		response       []byte
+		responseError  error
+		expectedResult any
+		expectedError  string
+	}{
+		{
+			name:           "getOptional of an existing resource",
+			expression:     "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').getOptional('config').?data.mode.orValue('')",
+			response:       configMap,
+			expectedResult: "strict",
+		},
+		{
+			name:           "getOptional of a missing resource",
+			expression:     "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').getOptional('config').hasValue()",
+			responseError:  errors.New(`configmaps "config" not found: NotFound (ErrorResponse { status: "Failure", message: "configmaps \"config\" not found", reason: "NotFound", code: 404 })`),
+			expectedResult: false,
+		},
+		{
+			name:          "getOptional failure",
+			expression:    "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').getOptional('config').hasValue()",
+			responseError: errors.New("connection refused"),
+			expectedError: "cannot get Kubernetes resource: connection refused",
+		},
+		{
+			name:           "has",
+			expression:     "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').has('config')",
+			response:       configMap,
+			expectedResult: true,
+		},
+		{
+			name:           "has of a missing resource",
+			expression:     "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').has('config')",
+			responseError:  errors.New(`configmaps "config" not found`),
+			expectedResult: false,
+		},
+		{
+			name:          "has failure",
+			expression:    "kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').has('config')",
+			responseError: errors.New("forbidden: cannot get resource"),
+			expectedError: "cannot get Kubernetes resource: forbidden: cannot get resource",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectedRequest, err := json.Marshal(kubernetes.GetResourceRequest{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Name:       "config",
+				Namespace:  stringPtr("default"),
+			})
+			require.NoError(t, err)
+
+			mockWapcClient := &mocks.MockWapcClient{}
+			mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", expectedRequest).Return(test.response, test.responseError)
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				cel.OptionalTypes(),
+				Kubernetes(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedResult, val.Value())
+		})
+	}
+}
+
+func TestKubernetesFresh(t *testing.T) {
+	resource, err := json.Marshal(&corev1.ConfigMap{
+		Metadata: &metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+	})
+	require.NoError(t, err)
+
+	cachedRequest, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: "v1", Kind: "ConfigMap", Name: "config", Namespace: stringPtr("default")})
+	require.NoError(t, err)
+	freshRequest, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: "v1", Kind: "ConfigMap", Name: "config", Namespace: stringPtr("default"), DisableCache: true})
+	require.NoError(t, err)
+	listRequest, err := json.Marshal(kubernetes.ListResourcesByNamespaceRequest{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default"})
+	require.NoError(t, err)
+
+	mockWapcClient := &mocks.MockWapcClient{}
+	mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", cachedRequest).Return(resource, nil)
+	mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", freshRequest).Return(resource, nil)
+	mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "list_resources_by_namespace", listRequest).Return([]byte(`{"items":[]}`), nil)
+
+	setHostClient(t, mockWapcClient)
+
+	env, err := cel.NewEnv(
+		Kubernetes(),
+	)
+	require.NoError(t, err)
+
+	// fresh calls disable the host cache and reach the host every time,
+	// while the others are memoized
+	ast, issues := env.Compile(`
+		[1, 2].all(i,
+			kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').get('config').metadata.name == 'config' &&
+			kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').fresh().get('config').metadata.name == 'config' &&
+			kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').fresh().list().items.size() == 0)`)
+	require.Empty(t, issues)
+
+	prog, err := env.Program(ast)
+	require.NoError(t, err)
+
+	val, _, err := prog.Eval(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, true, val.Value())
+
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 5)
+}
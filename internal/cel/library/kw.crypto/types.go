@@ -0,0 +1,158 @@
+// Package crypto declares the X.509 objects returned by the `kw.crypto` CEL library.
+// The objects describe the fields of the certificates commonly checked by the
+// policies, so that they can be declared as CEL native types and their fields type-checked.
+// The CEL field names are the JSON field names, given by the `json` struct tags.
+//
+// The directory is named `kw.crypto`, rather than `crypto`, because cel-go names
+// the native types after the last element of their package path: the certificates
+// are `kw.crypto.Certificate` objects, next to the `kw.crypto.Verifier` of the library.
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+)
+
+// Certificate is an X.509 certificate, see x509.Certificate.
+type Certificate struct {
+	Subject            Name      `json:"subject"`
+	Issuer             Name      `json:"issuer"`
+	SerialNumber       string    `json:"serialNumber"`
+	NotBefore          time.Time `json:"notBefore"`
+	NotAfter           time.Time `json:"notAfter"`
+	DNSNames           []string  `json:"dnsNames"`
+	IPAddresses        []string  `json:"ipAddresses"`
+	EmailAddresses     []string  `json:"emailAddresses"`
+	URIs               []string  `json:"uris"`
+	IsCA               bool      `json:"isCA"`
+	KeyAlgorithm       string    `json:"keyAlgorithm"`
+	KeySize            int       `json:"keySize"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm"`
+	KeyUsages          []string  `json:"keyUsages"`
+	ExtKeyUsages       []string  `json:"extKeyUsages"`
+}
+
+// Name is the subject or the issuer of a certificate, see pkix.Name.
+type Name struct {
+	CommonName         string   `json:"commonName"`
+	Organization       []string `json:"organization"`
+	OrganizationalUnit []string `json:"organizationalUnit"`
+	Country            []string `json:"country"`
+	Province           []string `json:"province"`
+	Locality           []string `json:"locality"`
+	// DistinguishedName is the RFC 2253 representation of the name, e.g. `CN=example.com,O=Example`.
+	DistinguishedName string `json:"distinguishedName"`
+}
+
+// keyUsages are the names of the key usages, as defined by RFC 5280.
+//
+//nolint:gochecknoglobals // slices cannot be const
+var keyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsages are the names of the extended key usages, as defined by RFC 5280
+// for the ones it defines.
+//
+//nolint:gochecknoglobals // maps cannot be const
+var extKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "microsoftServerGatedCrypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "netscapeServerGatedCrypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "microsoftCommercialCodeSigning",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "microsoftKernelCodeSigning",
+}
+
+// NewCertificate returns the certificate declared by the given X.509 certificate.
+func NewCertificate(certificate *x509.Certificate) *Certificate {
+	result := &Certificate{
+		Subject:            newName(certificate.Subject),
+		Issuer:             newName(certificate.Issuer),
+		NotBefore:          certificate.NotBefore,
+		NotAfter:           certificate.NotAfter,
+		DNSNames:           certificate.DNSNames,
+		EmailAddresses:     certificate.EmailAddresses,
+		IsCA:               certificate.IsCA,
+		KeyAlgorithm:       certificate.PublicKeyAlgorithm.String(),
+		KeySize:            keySize(certificate.PublicKey),
+		SignatureAlgorithm: certificate.SignatureAlgorithm.String(),
+		KeyUsages:          []string{},
+		ExtKeyUsages:       []string{},
+	}
+
+	if certificate.SerialNumber != nil {
+		result.SerialNumber = certificate.SerialNumber.String()
+	}
+	for _, ip := range certificate.IPAddresses {
+		result.IPAddresses = append(result.IPAddresses, ip.String())
+	}
+	for _, uri := range certificate.URIs {
+		result.URIs = append(result.URIs, uri.String())
+	}
+	for _, keyUsage := range keyUsages {
+		if certificate.KeyUsage&keyUsage.usage != 0 {
+			result.KeyUsages = append(result.KeyUsages, keyUsage.name)
+		}
+	}
+	for _, extKeyUsage := range certificate.ExtKeyUsage {
+		if name, ok := extKeyUsages[extKeyUsage]; ok {
+			result.ExtKeyUsages = append(result.ExtKeyUsages, name)
+		}
+	}
+	// the usages unknown to the Go library are reported by their OID
+	for _, oid := range certificate.UnknownExtKeyUsage {
+		result.ExtKeyUsages = append(result.ExtKeyUsages, oid.String())
+	}
+
+	return result
+}
+
+func newName(name pkix.Name) Name {
+	return Name{
+		CommonName:         name.CommonName,
+		Organization:       name.Organization,
+		OrganizationalUnit: name.OrganizationalUnit,
+		Country:            name.Country,
+		Province:           name.Province,
+		Locality:           name.Locality,
+		DistinguishedName:  name.String(),
+	}
+}
+
+// keySize returns the size of the public key in bits, 0 if the algorithm is not known.
+func keySize(publicKey any) int {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return len(key) * 8 //nolint:mnd // the number of bits of a byte
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,242 @@
+// Package oci declares the OCI objects returned by the `kw.oci` CEL library.
+// The objects mirror the structs of the OCI image specification, flattening the
+// embedded structs and renaming the fields that are not valid CEL identifiers,
+// so that they can be declared as CEL native types and their fields type-checked.
+// The CEL field names are the JSON field names, given by the `json` struct tags.
+//
+// cel-go names the native types after the last element of their package path,
+// hence the `kw.oci` directory: the objects are the `kw.oci.*` CEL types, like the
+// other objects of the library.
+package oci
+
+import (
+	"slices"
+	"time"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ManifestResponse is the manifest of an image: either Image or Index is set,
+// depending on the image being a single platform image or a multi-platform one.
+type ManifestResponse struct {
+	Image *Manifest `json:"image"`
+	Index *Index    `json:"index"`
+}
+
+// ManifestConfigResponse is the manifest, digest and configuration of an image.
+type ManifestConfigResponse struct {
+	Manifest Manifest    `json:"manifest"`
+	Digest   string      `json:"digest"`
+	Config   ImageConfig `json:"config"`
+}
+
+// Manifest is an OCI image manifest, see specs.Manifest.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Subject       *Descriptor       `json:"subject"`
+	Annotations   map[string]string `json:"annotations"`
+}
+
+// Index is an OCI image index, see specs.Index.
+type Index struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject"`
+	Annotations   map[string]string `json:"annotations"`
+}
+
+// Descriptor describes the content of a blob, see specs.Descriptor.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	URLs         []string          `json:"urls"`
+	Annotations  map[string]string `json:"annotations"`
+	Data         []byte            `json:"data"`
+	Platform     *Platform         `json:"platform"`
+	ArtifactType string            `json:"artifactType"`
+}
+
+// Platform describes the platform of an image, see specs.Platform.
+// The `os.version` and `os.features` fields are named `osVersion` and `osFeatures`.
+type Platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"osVersion"`
+	OSFeatures   []string `json:"osFeatures"`
+	Variant      string   `json:"variant"`
+}
+
+// ImageConfig is the configuration of an image, see specs.Image.
+// The fields of the platform are part of the configuration.
+type ImageConfig struct {
+	Created      time.Time       `json:"created"`
+	Author       string          `json:"author"`
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	OSVersion    string          `json:"osVersion"`
+	OSFeatures   []string        `json:"osFeatures"`
+	Variant      string          `json:"variant"`
+	Config       ExecutionConfig `json:"config"`
+	RootFS       RootFS          `json:"rootfs"`
+	History      []History       `json:"history"`
+}
+
+// ExecutionConfig is the execution parameters of an image, see specs.ImageConfig.
+// The exposed ports and the volumes are lists, instead of maps with empty values.
+type ExecutionConfig struct {
+	User         string            `json:"User"`
+	ExposedPorts []string          `json:"ExposedPorts"`
+	Env          []string          `json:"Env"`
+	Entrypoint   []string          `json:"Entrypoint"`
+	Cmd          []string          `json:"Cmd"`
+	Volumes      []string          `json:"Volumes"`
+	WorkingDir   string            `json:"WorkingDir"`
+	Labels       map[string]string `json:"Labels"`
+	StopSignal   string            `json:"StopSignal"`
+}
+
+// RootFS describes the layer content addresses of an image, see specs.RootFS.
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// History describes the history of a layer, see specs.History.
+type History struct {
+	Created    time.Time `json:"created"`
+	CreatedBy  string    `json:"created_by"`
+	Author     string    `json:"author"`
+	Comment    string    `json:"comment"`
+	EmptyLayer bool      `json:"empty_layer"`
+}
+
+// NewManifest returns the manifest declared by the given OCI image manifest.
+func NewManifest(manifest *specs.Manifest) *Manifest {
+	return &Manifest{
+		SchemaVersion: manifest.SchemaVersion,
+		MediaType:     manifest.MediaType,
+		ArtifactType:  manifest.ArtifactType,
+		Config:        *NewDescriptor(&manifest.Config),
+		Layers:        newDescriptors(manifest.Layers),
+		Subject:       NewDescriptor(manifest.Subject),
+		Annotations:   manifest.Annotations,
+	}
+}
+
+// NewIndex returns the index declared by the given OCI image index.
+func NewIndex(index *specs.Index) *Index {
+	return &Index{
+		SchemaVersion: index.SchemaVersion,
+		MediaType:     index.MediaType,
+		ArtifactType:  index.ArtifactType,
+		Manifests:     newDescriptors(index.Manifests),
+		Subject:       NewDescriptor(index.Subject),
+		Annotations:   index.Annotations,
+	}
+}
+
+// NewDescriptor returns the descriptor declared by the given OCI descriptor, nil if it is nil.
+func NewDescriptor(descriptor *specs.Descriptor) *Descriptor {
+	if descriptor == nil {
+		return nil
+	}
+
+	return &Descriptor{
+		MediaType:    descriptor.MediaType,
+		Digest:       descriptor.Digest.String(),
+		Size:         descriptor.Size,
+		URLs:         descriptor.URLs,
+		Annotations:  descriptor.Annotations,
+		Data:         descriptor.Data,
+		Platform:     newPlatform(descriptor.Platform),
+		ArtifactType: descriptor.ArtifactType,
+	}
+}
+
+func newDescriptors(descriptors []specs.Descriptor) []Descriptor {
+	result := make([]Descriptor, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		result = append(result, *NewDescriptor(&descriptor))
+	}
+
+	return result
+}
+
+func newPlatform(platform *specs.Platform) *Platform {
+	if platform == nil {
+		return nil
+	}
+
+	return &Platform{
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		OSVersion:    platform.OSVersion,
+		OSFeatures:   platform.OSFeatures,
+		Variant:      platform.Variant,
+	}
+}
+
+// NewImageConfig returns the configuration declared by the given OCI image configuration.
+func NewImageConfig(image *specs.Image) *ImageConfig {
+	config := &ImageConfig{
+		Author:       image.Author,
+		Architecture: image.Architecture,
+		OS:           image.OS,
+		OSVersion:    image.OSVersion,
+		OSFeatures:   image.OSFeatures,
+		Variant:      image.Variant,
+		Config: ExecutionConfig{
+			User:         image.Config.User,
+			ExposedPorts: keys(image.Config.ExposedPorts),
+			Env:          image.Config.Env,
+			Entrypoint:   image.Config.Entrypoint,
+			Cmd:          image.Config.Cmd,
+			Volumes:      keys(image.Config.Volumes),
+			WorkingDir:   image.Config.WorkingDir,
+			Labels:       image.Config.Labels,
+			StopSignal:   image.Config.StopSignal,
+		},
+		RootFS: RootFS{
+			Type: image.RootFS.Type,
+		},
+	}
+
+	if image.Created != nil {
+		config.Created = *image.Created
+	}
+	for _, diffID := range image.RootFS.DiffIDs {
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID.String())
+	}
+	for _, history := range image.History {
+		entry := History{
+			CreatedBy:  history.CreatedBy,
+			Author:     history.Author,
+			Comment:    history.Comment,
+			EmptyLayer: history.EmptyLayer,
+		}
+		if history.Created != nil {
+			entry.Created = *history.Created
+		}
+		config.History = append(config.History, entry)
+	}
+
+	return config
+}
+
+// keys returns the sorted keys of the map.
+func keys(m map[string]struct{}) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	slices.Sort(result)
+
+	return result
+}
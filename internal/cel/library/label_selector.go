@@ -0,0 +1,162 @@
+package library
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"google.golang.org/protobuf/types/known/structpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// FormatLabelSelector formats a metav1.LabelSelector into a string that can be understood
+// by Kubewarden host capabilities.
+func FormatLabelSelector(selector *metav1.LabelSelector) (string, error) {
+	labelSelectorBuilder := labels.NewSelector()
+	for key, value := range selector.MatchLabels {
+		requirement, err := labels.NewRequirement(key, selection.Equals, []string{value})
+		if err != nil {
+			return "", err
+		}
+		labelSelectorBuilder = labelSelectorBuilder.Add(*requirement)
+	}
+	for _, matchExpression := range selector.MatchExpressions {
+		operator, err := getOperationString(matchExpression.Operator)
+		if err != nil {
+			return "", err
+		}
+		requirement, err := labels.NewRequirement(matchExpression.Key, operator, matchExpression.Values)
+		if err != nil {
+			return "", err
+		}
+		labelSelectorBuilder = labelSelectorBuilder.Add(*requirement)
+	}
+
+	return labelSelectorBuilder.String(), nil
+}
+
+func getOperationString(op metav1.LabelSelectorOperator) (selection.Operator, error) {
+	if op == metav1.LabelSelectorOpIn {
+		return selection.In, nil
+	}
+	if op == metav1.LabelSelectorOpNotIn {
+		return selection.NotIn, nil
+	}
+	if op == metav1.LabelSelectorOpExists {
+		return selection.Exists, nil
+	}
+	if op == metav1.LabelSelectorOpDoesNotExist {
+		return selection.DoesNotExist, nil
+	}
+	return "", errors.New("unknown label selector operator")
+}
+
+// labelSelectorFromMap returns the label selector described by the given map.
+// A map having only string values is a map of labels, the same as the
+// `matchLabels` field. Any other map must be a LabelSelector object, having the
+// `matchLabels` and `matchExpressions` fields.
+func labelSelectorFromMap(mapper traits.Mapper) (*metav1.LabelSelector, error) {
+	matchLabels := map[string]string{}
+	for it := mapper.Iterator(); it.HasNext() == types.True; {
+		key := it.Next()
+		name, ok := key.(types.String)
+		if !ok {
+			return nil, fmt.Errorf("the keys must be strings, got %s", key.Type().TypeName())
+		}
+		value, ok := mapper.Get(key).(types.String)
+		if !ok {
+			matchLabels = nil
+			break
+		}
+		matchLabels[string(name)] = string(value)
+	}
+	if matchLabels != nil {
+		return &metav1.LabelSelector{MatchLabels: matchLabels}, nil
+	}
+
+	native, err := mapper.ConvertToNative(reflect.TypeOf(&structpb.Struct{}))
+	if err != nil {
+		return nil, err
+	}
+	object, ok := native.(*structpb.Struct)
+	if !ok {
+		return nil, fmt.Errorf("unexpected label selector type %T", native)
+	}
+	data, err := json.Marshal(object.AsMap())
+	if err != nil {
+		return nil, err
+	}
+
+	// unknown fields are rejected, an ignored field would select more resources than expected
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	selector := &metav1.LabelSelector{}
+	if err = decoder.Decode(selector); err != nil {
+		return nil, err
+	}
+
+	return selector, nil
+}
+
+// labelSelectorValidator reports the invalid label selectors given as string
+// literals to the `labelSelector` function, so that they are found when the
+// expression is compiled instead of when the host is called.
+type labelSelectorValidator struct{}
+
+func (labelSelectorValidator) Name() string {
+	return "kw.validator.labelSelector"
+}
+
+func (labelSelectorValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, a *ast.AST, issues *cel.Issues) {
+	for _, call := range ast.MatchDescendants(ast.NavigateAST(a), ast.FunctionMatcher("labelSelector")) {
+		args := call.AsCall().Args()
+		if len(args) != 1 || args[0].Kind() != ast.LiteralKind {
+			continue
+		}
+
+		selector, ok := args[0].AsLiteral().(types.String)
+		if !ok {
+			continue
+		}
+
+		if _, err := labels.Parse(string(selector)); err != nil {
+			issues.ReportErrorAtID(args[0].ID(), "invalid label selector: %s", err)
+		}
+	}
+}
+
+func k8sClientLabelSelectorMap(arg1, arg2 ref.Val) ref.Val {
+	client, ok := arg1.(k8sClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	mapper, ok := arg2.(traits.Mapper)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	selector, err := labelSelectorFromMap(mapper)
+	if err != nil {
+		return types.NewErr("invalid label selector: %s", err)
+	}
+
+	labelSelector, err := FormatLabelSelector(selector)
+	if err != nil {
+		return types.NewErr("invalid label selector: %s", err)
+	}
+
+	client.labelSelector = &labelSelector
+
+	return client
+}
@@ -2,7 +2,73 @@
 // context-aware capabilities as CEL functions for the policy to use.
 package library
 
-import "github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+import (
+	"sync"
 
-// handle to interact with the policy host.
-var host = capabilities.NewHost()
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+)
+
+//nolint:gochecknoglobals // the cache is shared by the libraries and the policy
+var (
+	// cache memoizes the host capabilities calls done while evaluating a request.
+	cache = newHostCache(capabilities.NewHost().Client)
+	// handle to interact with the policy host.
+	host = capabilities.Host{Client: cache}
+	// namespaces caches the Namespace objects fetched while evaluating a request, by name.
+	namespaces = map[string]ref.Val{}
+)
+
+// SetHostClient replaces the client used to interact with the policy host.
+// This allows to run the policy outside of the policy host, for example when
+// testing policies. The responses memoized from the previous client are discarded.
+// It returns the previous client, so that it can be restored.
+func SetHostClient(client capabilities.WapcClient) capabilities.WapcClient {
+	previous := cache.client
+	cache.client = client
+	ResetHostCache()
+
+	return previous
+}
+
+// HostClient returns the client used to interact with the policy host.
+// The client memoizes the responses of the host, so it must be shared by
+// everything that calls the host capabilities while evaluating a request.
+func HostClient() capabilities.WapcClient {
+	return cache
+}
+
+// nativeTypeAdapter converts the objects declared as CEL native types by the
+// libraries, like the OCI manifests and the X.509 certificates, into CEL values.
+// The bindings of the functions have no access to the adapter of the environment,
+// hence the adapter of an environment declaring only the native types is used.
+//
+//nolint:gochecknoglobals // the adapter is created once
+var nativeTypeAdapter = sync.OnceValues(func() (types.Adapter, error) {
+	env, err := cel.NewEnv(ociNativeTypes(), cryptoNativeTypes())
+	if err != nil {
+		return nil, err
+	}
+
+	return env.CELTypeAdapter(), nil
+})
+
+// nativeValue converts an object declared as a CEL native type into a CEL value.
+func nativeValue(object any) ref.Val {
+	adapter, err := nativeTypeAdapter()
+	if err != nil {
+		return types.NewErr("cannot declare the native types: %s", err)
+	}
+
+	return adapter.NativeToValue(object)
+}
+
+// ResetHostCache discards the host responses, and the objects decoded from
+// them, memoized while evaluating the previous request.
+// It must be called before evaluating a new request.
+func ResetHostCache() {
+	cache.reset()
+	namespaces = map[string]ref.Val{}
+}
@@ -43,7 +43,7 @@ func TestNet(t *testing.T) {
 			mockWapcClient := &mocks.MockWapcClient{}
 			mockWapcClient.On("HostCall", "kubewarden", "net", test.expectedOperation, expectedRequest).Return(response, nil)
 
-			host.Client = mockWapcClient
+			setHostClient(t, mockWapcClient)
 
 			env, err := cel.NewEnv(
 				Net(),
@@ -2,15 +2,37 @@ package library
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	oci "github.com/kubewarden/cel-policy/internal/cel/library/kw.oci"
+	manifestCap "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/manifest"
+	manifestConfigCap "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/manifest_config"
 	manifestDigestCap "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/manifest_digest"
+	godigest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // OCI provides a CEL function library extension for retrieving the manifest of a given image.
 //
+// The manifests and configurations are returned as typed objects, so that the fields
+// accessed by the expressions are checked when the expressions are compiled.
+// The objects are declared as `kw.oci.ManifestResponse`, `kw.oci.ManifestConfigResponse`,
+// `kw.oci.Manifest`, `kw.oci.Index`, `kw.oci.Descriptor`, `kw.oci.Platform` and `kw.oci.ImageConfig`,
+// and their fields are named after the JSON fields of the OCI image specification.
+// The `os.version` and `os.features` platform fields are named `osVersion` and `osFeatures`,
+// while the `ExposedPorts` and `Volumes` fields of the image configuration are the sorted
+// lists of the keys of the maps. The timestamps that are not set are zero, `has()` is false for them.
+//
 // image
 //
 // Returns an OCI client object that can be used to retrieve the manifest of the provided image.
@@ -28,18 +50,19 @@ import (
 // or a OCI index image manifest. See more at:
 // https://github.com/opencontainers/image-spec/blob/main/manifest.md
 // https://github.com/opencontainers/image-spec/blob/main/image-index.md
-// If the response is an OCI index image manifest, the image field will be nil.
-// If the response is an OCI image manifest, the index field will be nil.
+// If the response is an OCI index image manifest, the image field is not set.
+// If the response is an OCI image manifest, the index field is not set.
 //
-//	<OCIClient>.manifest() <DynamicMap>
+//	<OCIClient>.manifest() <ManifestResponse>
 //
 // Examples:
 //
-//	kw.oci.image('image:latest').manifest().index // returns the index manfest, the image field is nil
+//	kw.oci.image('image:latest').manifest().index // returns the index manifest, has(manifest().image) is false
 //
 // or
 //
-//	kw.oci.image('image:latest').manifest().image // returns the image manifest, the index field is nil
+//	kw.oci.image('image:latest').manifest().image // returns the image manifest, has(manifest().index) is false
+//	kw.oci.image('image:latest').manifest().image.layers.size() // returns the number of layers of the image
 //
 // manifestDigest
 //
@@ -59,13 +82,184 @@ import (
 // https://github.com/opencontainers/image-spec/blob/main/manifest.md
 // https://github.com/opencontainers/image-spec/blob/main/config.md
 //
-//	<OCIClient>.manifestConfig() <DynamicMap>
+//	<OCIClient>.manifestConfig() <ManifestConfigResponse>
 //
 // Examples:
 //
 //	kw.oci.image('image:latest').manifestConfig().manifest // returns the image manifest
 //	kw.oci.image('image:latest').manifestConfig().config // returns the image configuration
 //	kw.oci.image('image:latest').manifestConfig().digest // returns the image digest
+//	kw.oci.image('image:latest').manifestConfig().config.config.User // returns the user the image runs as
+//
+// runsAsRoot
+//
+// Returns true if the image runs as root: its configuration has no user, or the user is `0` or `root`.
+//
+//	<ManifestConfigResponse>.runsAsRoot() <bool>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().runsAsRoot() // returns true if the image runs as root
+//
+// createdAt
+//
+// Returns the creation time of the image, the Unix epoch if the image configuration has no creation time.
+//
+//	<ManifestConfigResponse>.createdAt() <timestamp>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().createdAt() > timestamp('2024-01-01T00:00:00Z') // returns true if the image was built in 2024 or later
+//
+// labels
+//
+// Returns the labels of the image configuration, like the OCI annotations `org.opencontainers.image.*`.
+//
+//	<ManifestConfigResponse>.labels() <map<string, string>>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().labels()['org.opencontainers.image.source'] // returns the source of the image
+//
+// exposedPorts
+//
+// Returns the ports exposed by the image configuration, e.g. `80/tcp`.
+//
+//	<ManifestConfigResponse>.exposedPorts() <list<string>>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().exposedPorts() // returns ['80/tcp']
+//
+// totalSize
+//
+// Returns the total size of the layers of the image, in bytes.
+//
+//	<ManifestConfigResponse>.totalSize() <int>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().totalSize() < 500 * 1024 * 1024 // returns true if the image is smaller than 500MiB
+//
+// layerCount
+//
+// Returns the number of layers of the image.
+//
+//	<ManifestConfigResponse>.layerCount() <int>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestConfig().layerCount() <= 10 // returns true if the image has at most 10 layers
+//
+// platforms
+//
+// Returns the platforms supported by the image, as `os/architecture[/variant]` strings.
+// The platforms of a multi-platform image are the ones of the entries of its index,
+// skipping the entries that are not images, like the attestations.
+// The platform of a single platform image is the one of its configuration.
+// The default variant of the architecture is omitted: the `v8` variant of `arm64` images
+// is returned as `linux/arm64`, the platform matched by `manifestFor('linux/arm64')`.
+//
+//	<OCIClient>.platforms() <list<string>>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').platforms() // returns ['linux/amd64', 'linux/arm/v7', 'linux/arm64', ...]
+//	'linux/arm64' in kw.oci.image('nginx:latest').platforms() // returns true if the image supports the linux/arm64 platform
+//
+// manifestFor
+//
+// Returns the manifest, digest and image configuration of the image for the provided platform,
+// given as `os/architecture[/variant]`. When the variant is not given, any variant of the platform matches.
+// An error is returned when the image does not support the platform.
+//
+//	<OCIClient>.manifestFor(<string>) <ManifestConfigResponse>
+//
+// Examples:
+//
+//	kw.oci.image('nginx:latest').manifestFor('linux/arm64').digest // returns the digest of the linux/arm64 image
+//	kw.oci.image('nginx:latest').manifestFor('linux/arm64').config.config.User // returns the user the linux/arm64 image runs as
+//
+// parseReference
+//
+// Returns the image reference parsed from the provided string, following the grammar of the
+// distribution references, without calling the policy host.
+// References without registry are Docker Hub references, the same as for the container runtimes:
+// the registry is `docker.io`, and the `library/` prefix is added to official images repositories.
+// Invalid references are returned as errors.
+//
+//	kw.oci.parseReference(<string>) <Reference>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx') // returns the Reference of 'docker.io/library/nginx'
+//	kw.oci.parseReference('ghcr.io/kubewarden/policy-server:v1.0.0') // returns the Reference of 'ghcr.io/kubewarden/policy-server:v1.0.0'
+//
+// registry
+//
+// Returns the registry of the image reference, including the port if any.
+//
+//	<Reference>.registry() <string>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx').registry() // returns 'docker.io'
+//	kw.oci.parseReference('localhost:5000/app').registry() // returns 'localhost:5000'
+//
+// repository
+//
+// Returns the repository of the image reference, without the registry.
+//
+//	<Reference>.repository() <string>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx:1.27').repository() // returns 'library/nginx'
+//	kw.oci.parseReference('ghcr.io/kubewarden/policy-server').repository() // returns 'kubewarden/policy-server'
+//
+// tag
+//
+// Returns the tag of the image reference, or an empty string if the reference has no tag.
+//
+//	<Reference>.tag() <string>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx:1.27').tag() // returns '1.27'
+//	kw.oci.parseReference('nginx').tag() // returns ''
+//
+// digest
+//
+// Returns the digest of the image reference, or an empty string if the reference has no digest.
+//
+//	<Reference>.digest() <string>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31').digest() // returns 'sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31'
+//
+// normalized
+//
+// Returns the fully qualified image reference, having the registry and the repository.
+// The 'latest' tag is added when the reference has neither a tag nor a digest.
+//
+//	<Reference>.normalized() <string>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx').normalized() // returns 'docker.io/library/nginx:latest'
+//	kw.oci.parseReference('index.docker.io/bitnami/redis:7.2').normalized() // returns 'docker.io/bitnami/redis:7.2'
+//
+// isDigestPinned
+//
+// Returns true if the image reference has a digest.
+//
+//	<Reference>.isDigestPinned() <bool>
+//
+// Examples:
+//
+//	kw.oci.parseReference('nginx:1.27').isDigestPinned() // returns false
+//	kw.oci.parseReference('nginx:1.27@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31').isDigestPinned() // returns true
 func OCI() cel.EnvOption {
 	return cel.Lib(&ociLib{})
 }
@@ -78,6 +272,7 @@ func (*ociLib) LibraryName() string {
 
 func (*ociLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
+		ociNativeTypes(),
 		cel.Function("kw.oci.image",
 			cel.Overload("kw_oci_image",
 				[]*cel.Type{cel.StringType},
@@ -88,7 +283,7 @@ func (*ociLib) CompileOptions() []cel.EnvOption {
 		cel.Function("manifest",
 			cel.MemberOverload("kw_k8s_manifest",
 				[]*cel.Type{ociClientType},
-				cel.DynType,
+				ociManifestResponseType,
 				cel.UnaryBinding(ociClientManifest),
 			),
 		),
@@ -102,10 +297,122 @@ func (*ociLib) CompileOptions() []cel.EnvOption {
 		cel.Function("manifestConfig",
 			cel.MemberOverload("kw_k8s_manifest_config",
 				[]*cel.Type{ociClientType},
-				cel.DynType,
+				ociManifestConfigResponseType,
 				cel.UnaryBinding(ociClientManifestConfig),
 			),
 		),
+		cel.Function("runsAsRoot",
+			cel.MemberOverload("kw_oci_manifest_config_runs_as_root",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.BoolType,
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val { return types.Bool(runsAsRoot(r.Config.Config.User)) })),
+			),
+		),
+		cel.Function("createdAt",
+			cel.MemberOverload("kw_oci_manifest_config_created_at",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.TimestampType,
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val { return createdAt(r.Config.Created) })),
+			),
+		),
+		cel.Function("labels",
+			cel.MemberOverload("kw_oci_manifest_config_labels",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.MapType(cel.StringType, cel.StringType),
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val {
+					if r.Config.Config.Labels == nil {
+						return types.NewStringStringMap(types.DefaultTypeAdapter, map[string]string{})
+					}
+					return types.NewStringStringMap(types.DefaultTypeAdapter, r.Config.Config.Labels)
+				})),
+			),
+		),
+		cel.Function("exposedPorts",
+			cel.MemberOverload("kw_oci_manifest_config_exposed_ports",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.ListType(cel.StringType),
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val {
+					return types.NewStringList(types.DefaultTypeAdapter, r.Config.Config.ExposedPorts)
+				})),
+			),
+		),
+		cel.Function("totalSize",
+			cel.MemberOverload("kw_oci_manifest_config_total_size",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.IntType,
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val { return types.Int(totalSize(r.Manifest.Layers)) })),
+			),
+		),
+		cel.Function("layerCount",
+			cel.MemberOverload("kw_oci_manifest_config_layer_count",
+				[]*cel.Type{ociManifestConfigResponseType},
+				cel.IntType,
+				cel.UnaryBinding(ociManifestConfigField(func(r *oci.ManifestConfigResponse) ref.Val { return types.Int(len(r.Manifest.Layers)) })),
+			),
+		),
+		cel.Function("platforms",
+			cel.MemberOverload("kw_oci_platforms",
+				[]*cel.Type{ociClientType},
+				cel.ListType(cel.StringType),
+				cel.UnaryBinding(ociClientPlatforms),
+			),
+		),
+		cel.Function("manifestFor",
+			cel.MemberOverload("kw_oci_manifest_for",
+				[]*cel.Type{ociClientType, cel.StringType},
+				ociManifestConfigResponseType,
+				cel.BinaryBinding(ociClientManifestFor),
+			),
+		),
+		cel.Function("kw.oci.parseReference",
+			cel.Overload("kw_oci_parse_reference",
+				[]*cel.Type{cel.StringType},
+				ociReferenceType,
+				cel.UnaryBinding(ociParseReference),
+			),
+		),
+		cel.Function("registry",
+			cel.MemberOverload("kw_oci_reference_registry",
+				[]*cel.Type{ociReferenceType},
+				cel.StringType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.String(r.registry) })),
+			),
+		),
+		cel.Function("repository",
+			cel.MemberOverload("kw_oci_reference_repository",
+				[]*cel.Type{ociReferenceType},
+				cel.StringType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.String(r.repository) })),
+			),
+		),
+		cel.Function("tag",
+			cel.MemberOverload("kw_oci_reference_tag",
+				[]*cel.Type{ociReferenceType},
+				cel.StringType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.String(r.tag) })),
+			),
+		),
+		cel.Function("digest",
+			cel.MemberOverload("kw_oci_reference_digest",
+				[]*cel.Type{ociReferenceType},
+				cel.StringType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.String(r.digest) })),
+			),
+		),
+		cel.Function("normalized",
+			cel.MemberOverload("kw_oci_reference_normalized",
+				[]*cel.Type{ociReferenceType},
+				cel.StringType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.String(r.normalized()) })),
+			),
+		),
+		cel.Function("isDigestPinned",
+			cel.MemberOverload("kw_oci_reference_is_digest_pinned",
+				[]*cel.Type{ociReferenceType},
+				cel.BoolType,
+				cel.UnaryBinding(ociReferenceField(func(r ociReference) ref.Val { return types.Bool(r.digest != "") })),
+			),
+		),
 	}
 }
 
@@ -143,6 +450,29 @@ func ociClientManifestConfig(arg ref.Val) ref.Val {
 	return ociClient.manifestConfig()
 }
 
+func ociClientPlatforms(arg ref.Val) ref.Val {
+	ociClient, ok := arg.(ociClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return ociClient.platforms()
+}
+
+func ociClientManifestFor(arg1, arg2 ref.Val) ref.Val {
+	ociClient, ok := arg1.(ociClient)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	platform, ok := arg2.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	return ociClient.manifestFor(platform)
+}
+
 func ociClientManifestDigest(arg ref.Val) ref.Val {
 	ociClient, ok := arg.(ociClient)
 	if !ok {
@@ -154,6 +484,20 @@ func ociClientManifestDigest(arg ref.Val) ref.Val {
 
 var ociClientType = cel.ObjectType("kw.oci.OCIClient")
 
+var (
+	ociManifestResponseType       = cel.ObjectType("kw.oci.ManifestResponse")
+	ociManifestConfigResponseType = cel.ObjectType("kw.oci.ManifestConfigResponse")
+)
+
+// ociNativeTypes declares the OCI objects, and the objects of their fields, as CEL types.
+func ociNativeTypes() cel.EnvOption {
+	return ext.NativeTypes(
+		ext.ParseStructTag("json"),
+		reflect.TypeOf(&oci.ManifestResponse{}),
+		reflect.TypeOf(&oci.ManifestConfigResponse{}),
+	)
+}
+
 // ociClient is a the client to interact with OCI-related capabilities.
 type ociClient struct {
 	receiverOnlyObjectVal
@@ -161,25 +505,60 @@ type ociClient struct {
 }
 
 func (c *ociClient) manifest() ref.Val {
+	response, err := c.fetchManifest()
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+
+	result := &oci.ManifestResponse{}
+	if response.Image != nil {
+		result.Image = oci.NewManifest(response.Image)
+	}
+	if response.Index != nil {
+		result.Index = oci.NewIndex(response.Index)
+	}
+
+	return nativeValue(result)
+}
+
+func (c *ociClient) fetchManifest() (*manifestCap.OciImageManifestResponse, error) {
 	payload, err := json.Marshal(c.image)
 	if err != nil {
-		return types.NewErr("failed to marshal image name: %s", err)
+		return nil, fmt.Errorf("failed to marshal image name: %w", err)
 	}
 
-	// We are using the host.Client.HostCall method to call the host directly as the SDK call
-	// returns a struct with external types but we want to return a DynamicMap that maps to the JSON response.
+	// We are using the host.Client.HostCall method to call the host directly as the SDK
+	// expects the bare manifest, while the response may wrap it in the image or index field.
 	responsePayload, err := host.Client.HostCall("kubewarden", "oci", "v1/oci_manifest", payload)
 	if err != nil {
-		return types.NewErr("failed to call host: %s", err)
+		return nil, fmt.Errorf("failed to call host: %w", err)
 	}
 
-	var response map[string]interface{}
-	err = json.Unmarshal(responsePayload, &response)
+	response, err := unmarshalManifestResponse(responsePayload)
 	if err != nil {
-		return types.NewErr("failed to unmarshal response payload: %s", err)
+		return nil, fmt.Errorf("failed to unmarshal response payload: %w", err)
 	}
 
-	return types.NewDynamicMap(types.DefaultTypeAdapter, response)
+	return response, nil
+}
+
+// unmarshalManifestResponse returns the manifest of the response, which has
+// either the image or the index field set, or it is the bare manifest.
+func unmarshalManifestResponse(payload []byte) (*manifestCap.OciImageManifestResponse, error) {
+	var wrapped struct {
+		Image *specs.Manifest `json:"image"`
+		Index *specs.Index    `json:"index"`
+	}
+	if err := json.Unmarshal(payload, &wrapped); err == nil && (wrapped.Image != nil || wrapped.Index != nil) {
+		return &manifestCap.OciImageManifestResponse{Image: wrapped.Image, Index: wrapped.Index}, nil
+	}
+
+	response := &manifestCap.OciImageManifestResponse{}
+	if err := json.Unmarshal(payload, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
 func (c *ociClient) manifestDigest() ref.Val {
@@ -192,23 +571,328 @@ func (c *ociClient) manifestDigest() ref.Val {
 }
 
 func (c *ociClient) manifestConfig() ref.Val {
-	payload, err := json.Marshal(c.image)
+	return fetchManifestConfig(c.image)
+}
+
+func fetchManifestConfig(image string) ref.Val {
+	response, err := manifestConfigCap.GetOCIManifestAndConfig(&host, image)
 	if err != nil {
-		return types.NewErr("failed to marshal image name: %s", err)
+		return types.NewErr("cannot get oci manifest and config: %s", err)
+	}
+
+	result := &oci.ManifestConfigResponse{
+		Digest: response.Digest,
+	}
+	if response.Manifest != nil {
+		result.Manifest = *oci.NewManifest(response.Manifest)
+	}
+	if response.ImageConfig != nil {
+		result.Config = *oci.NewImageConfig(response.ImageConfig)
 	}
 
-	// We are using the host.Client.HostCall method to call the host directly as the SDK call
-	// returns a struct with external types but we want to return a DynamicMap that maps to the JSON response.
-	responsePayload, err := host.Client.HostCall("kubewarden", "oci", "v1/oci_manifest_config", payload)
+	return nativeValue(result)
+}
+
+// platforms returns the platforms of the image: the platforms of the entries of
+// the index, or the platform of the image configuration for single platform images.
+// The entries of the index with an unknown platform, like the attestations, are skipped.
+func (c *ociClient) platforms() ref.Val {
+	response, err := c.fetchManifest()
 	if err != nil {
-		return types.NewErr("failed to call host: %s", err)
+		return types.NewErr("%s", err)
+	}
+
+	if response.Index == nil {
+		image, err := manifestConfigCap.GetOCIManifestAndConfig(&host, c.image)
+		if err != nil {
+			return types.NewErr("cannot get oci manifest and config: %s", err)
+		}
+		if image.ImageConfig == nil {
+			return types.NewStringList(types.DefaultTypeAdapter, []string{})
+		}
+
+		return types.NewStringList(types.DefaultTypeAdapter, []string{formatPlatform(&image.ImageConfig.Platform)})
+	}
+
+	platforms := []string{}
+	for _, descriptor := range response.Index.Manifests {
+		if descriptor.Platform == nil || descriptor.Platform.OS == unknownPlatform || descriptor.Platform.Architecture == unknownPlatform {
+			continue
+		}
+		platform := formatPlatform(descriptor.Platform)
+		if !slices.Contains(platforms, platform) {
+			platforms = append(platforms, platform)
+		}
+	}
+
+	return types.NewStringList(types.DefaultTypeAdapter, platforms)
+}
+
+// manifestFor returns the manifest, digest and configuration of the image for the given platform.
+func (c *ociClient) manifestFor(platform string) ref.Val {
+	wanted, err := parsePlatform(platform)
+	if err != nil {
+		return types.NewErr("invalid platform '%s': %s", platform, err)
+	}
+
+	response, err := c.fetchManifest()
+	if err != nil {
+		return types.NewErr("%s", err)
+	}
+
+	if response.Index == nil {
+		result := fetchManifestConfig(c.image)
+		if types.IsError(result) {
+			return result
+		}
+		manifestConfig, ok := result.Value().(*oci.ManifestConfigResponse)
+		if !ok {
+			return types.NewErr("unexpected manifest configuration of image '%s': %T", c.image, result.Value())
+		}
+		config := manifestConfig.Config
+		if !matchPlatform(wanted, &specs.Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}) {
+			return types.NewErr("image '%s' does not support the platform '%s'", c.image, platform)
+		}
+
+		return result
+	}
+
+	for _, descriptor := range response.Index.Manifests {
+		if descriptor.Platform == nil || !matchPlatform(wanted, descriptor.Platform) {
+			continue
+		}
+
+		reference, err := parseReference(c.image)
+		if err != nil {
+			return types.NewErr("invalid image reference '%s': %s", c.image, err)
+		}
+
+		return fetchManifestConfig(fmt.Sprintf("%s/%s@%s", reference.registry, reference.repository, descriptor.Digest))
+	}
+
+	return types.NewErr("image '%s' does not support the platform '%s'", c.image, platform)
+}
+
+// ociManifestConfigField returns the binding returning a property of the manifest and configuration of an image.
+func ociManifestConfigField(field func(*oci.ManifestConfigResponse) ref.Val) func(ref.Val) ref.Val {
+	return func(arg ref.Val) ref.Val {
+		response, ok := arg.Value().(*oci.ManifestConfigResponse)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(arg)
+		}
+
+		return field(response)
+	}
+}
+
+// runsAsRoot returns true if the user of the image configuration, given as
+// `user[:group]`, is root. Images without a user run as root.
+func runsAsRoot(user string) bool {
+	user, _, _ = strings.Cut(user, ":")
+
+	return user == "" || user == "0" || user == "root"
+}
+
+// createdAt returns the creation time, the Unix epoch if it is not set.
+func createdAt(created time.Time) ref.Val {
+	if created.IsZero() {
+		return types.Timestamp{Time: time.Unix(0, 0).UTC()}
 	}
 
-	var response map[string]interface{}
-	err = json.Unmarshal(responsePayload, &response)
+	return types.Timestamp{Time: created}
+}
+
+func totalSize(layers []oci.Descriptor) int64 {
+	var size int64
+	for _, layer := range layers {
+		size += layer.Size
+	}
+
+	return size
+}
+
+// unknownPlatform is the os and architecture of the index entries that are not images, like the attestations.
+const unknownPlatform = "unknown"
+
+// formatPlatform returns the platform as `os/architecture[/variant]`, omitting the
+// default variant of the architecture, so that `linux/arm64/v8` is `linux/arm64`.
+func formatPlatform(platform *specs.Platform) string {
+	parts := []string{platform.OS, platform.Architecture}
+	if platform.Variant != "" && platform.Variant != defaultVariant(&specs.Platform{Architecture: platform.Architecture}) {
+		parts = append(parts, platform.Variant)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// parsePlatform parses a platform given as `os/architecture[/variant]`.
+func parsePlatform(platform string) (*specs.Platform, error) {
+	parts := strings.Split(platform, "/")
+	//nolint:mnd // the platform has two or three parts
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return nil, errors.New("the platform must be in the form 'os/architecture[/variant]'")
+	}
+
+	result := &specs.Platform{OS: parts[0], Architecture: parts[1]}
+	//nolint:mnd // the variant is the third part
+	if len(parts) == 3 {
+		result.Variant = parts[2]
+	}
+
+	return result, nil
+}
+
+// matchPlatform returns true if the platform satisfies the wanted one.
+// When the wanted platform has no variant, any variant matches, otherwise the
+// variants must be the same. The `arm64` images have the `v8` variant by default.
+func matchPlatform(wanted, platform *specs.Platform) bool {
+	if wanted.OS != platform.OS || wanted.Architecture != platform.Architecture {
+		return false
+	}
+	if wanted.Variant == "" {
+		return true
+	}
+
+	return defaultVariant(platform) == defaultVariant(wanted)
+}
+
+func defaultVariant(platform *specs.Platform) string {
+	if platform.Variant == "" && platform.Architecture == "arm64" {
+		return "v8"
+	}
+
+	return platform.Variant
+}
+
+func ociParseReference(arg ref.Val) ref.Val {
+	image, ok := arg.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	reference, err := parseReference(image)
 	if err != nil {
-		return types.NewErr("failed to unmarshal response payload: %s", err)
+		return types.NewErr("invalid image reference '%s': %s", image, err)
+	}
+
+	return reference
+}
+
+// ociReferenceField returns the binding returning a field of the reference.
+func ociReferenceField(field func(ociReference) ref.Val) func(ref.Val) ref.Val {
+	return func(arg ref.Val) ref.Val {
+		reference, ok := arg.(ociReference)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(arg)
+		}
+
+		return field(reference)
+	}
+}
+
+var ociReferenceType = cel.ObjectType("kw.oci.Reference")
+
+const (
+	dockerHubRegistry       = "docker.io"
+	legacyDockerHubRegistry = "index.docker.io"
+	officialRepositoryPath  = "library/"
+	defaultTag              = "latest"
+	// maxNameLength is the maximum length of the name, registry and repository, of a reference.
+	maxNameLength = 255
+)
+
+// The grammar of the references, from github.com/distribution/reference.
+const (
+	referencePathComponent = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	referenceDomain        = `(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?`
+	referenceTag           = `[\w][\w.-]{0,127}`
+	referenceDigest        = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+)
+
+var (
+	// referenceRegexp captures the name, the tag and the digest of a reference.
+	referenceRegexp = regexp.MustCompile(`^((?:` + referenceDomain + `/)?` + referencePathComponent + `(?:/` + referencePathComponent + `)*)(?::(` + referenceTag + `))?(?:@(` + referenceDigest + `))?$`)
+	// anchoredDomainRegexp matches a registry.
+	anchoredDomainRegexp = regexp.MustCompile(`^` + referenceDomain + `$`)
+	// anchoredHexRegexp matches the names that cannot be told apart from image IDs.
+	anchoredHexRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// ociReference is an image reference, normalized the same as the container runtimes do.
+type ociReference struct {
+	receiverOnlyObjectVal
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseReference parses the reference, adding the Docker Hub defaults to the references without registry.
+func parseReference(image string) (ociReference, error) {
+	matches := referenceRegexp.FindStringSubmatch(image)
+	if matches == nil {
+		if strings.ToLower(image) != image && referenceRegexp.MatchString(strings.ToLower(image)) {
+			return ociReference{}, errors.New("repository name must be lowercase")
+		}
+		return ociReference{}, errors.New("invalid reference format")
+	}
+
+	name, tag, digest := matches[1], matches[2], matches[3]
+	if len(name) > maxNameLength {
+		return ociReference{}, fmt.Errorf("repository name must not be more than %d characters", maxNameLength)
+	}
+	if digest != "" {
+		if err := godigest.Digest(digest).Validate(); err != nil {
+			return ociReference{}, err
+		}
+	}
+
+	registry, repository := splitRegistry(name)
+	if anchoredHexRegexp.MatchString(repository) {
+		return ociReference{}, errors.New("repository name cannot be a 64-byte hexadecimal string")
+	}
+	if registry == dockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = officialRepositoryPath + repository
+	}
+
+	return ociReference{
+		receiverOnlyObjectVal: receiverOnlyVal(ociReferenceType),
+		registry:              registry,
+		repository:            repository,
+		tag:                   tag,
+		digest:                digest,
+	}, nil
+}
+
+// splitRegistry splits the name into registry and repository.
+// The first component of the name is the registry when it looks like a host name:
+// it has a dot or a port, or it is 'localhost'. Otherwise, the name is a Docker Hub repository.
+func splitRegistry(name string) (string, string) {
+	registry, repository, found := strings.Cut(name, "/")
+	if !found || !anchoredDomainRegexp.MatchString(registry) ||
+		(!strings.ContainsAny(registry, ".:") && registry != "localhost" && strings.ToLower(registry) == registry) {
+		registry, repository = dockerHubRegistry, name
+	}
+
+	if registry == legacyDockerHubRegistry {
+		registry = dockerHubRegistry
+	}
+
+	return registry, repository
+}
+
+// normalized returns the fully qualified reference.
+func (r ociReference) normalized() string {
+	reference := r.registry + "/" + r.repository
+	if r.tag != "" {
+		reference += ":" + r.tag
+	}
+	if r.digest != "" {
+		reference += "@" + r.digest
+	}
+	if r.tag == "" && r.digest == "" {
+		reference += ":" + defaultTag
 	}
 
-	return types.NewDynamicMap(types.DefaultTypeAdapter, response)
+	return reference
 }
@@ -3,7 +3,9 @@ package library
 import (
 	"encoding/json"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/cel-go/cel"
 
@@ -17,6 +19,8 @@ import (
 )
 
 func TestOCI(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
 	tests := []struct {
 		name              string
 		expression        string
@@ -37,6 +41,33 @@ func TestOCI(t *testing.T) {
 			},
 			specs.MediaTypeImageManifest,
 		},
+		{
+			"manifest (index)",
+			"!has(kw.oci.image('image:latest').manifest().image) && kw.oci.image('image:latest').manifest().index.manifests.map(m, m.platform.architecture) == ['amd64', 'arm64']",
+			"v1/oci_manifest",
+			"image:latest",
+			manifestCap.OciImageManifestResponse{
+				Index: &specs.Index{
+					MediaType: specs.MediaTypeImageIndex,
+					Manifests: []specs.Descriptor{
+						{Digest: "sha256:1234", Platform: &specs.Platform{OS: "linux", Architecture: "amd64"}},
+						{Digest: "sha256:5678", Platform: &specs.Platform{OS: "linux", Architecture: "arm64"}},
+					},
+				},
+			},
+			true,
+		},
+		{
+			"manifest (bare manifest)",
+			"kw.oci.image('image:latest').manifest().image.layers[0].size",
+			"v1/oci_manifest",
+			"image:latest",
+			specs.Manifest{
+				MediaType: specs.MediaTypeImageManifest,
+				Layers:    []specs.Descriptor{{Digest: "sha256:1234", Size: 1024}},
+			},
+			int64(1024),
+		},
 		{
 			"manifestDigest",
 			"kw.oci.image('image:latest').manifestDigest()",
@@ -59,6 +90,35 @@ func TestOCI(t *testing.T) {
 			},
 			"author",
 		},
+		{
+			"manifestConfig (typed fields)",
+			"kw.oci.image('image:latest').manifestConfig().config.config.ExposedPorts == ['443/tcp', '80/tcp'] && kw.oci.image('image:latest').manifestConfig().config.created == timestamp('2024-01-02T03:04:05Z')",
+			"v1/oci_manifest_config",
+			"image:latest",
+			manifestCapConfig.OciImageManifestAndConfigResponse{
+				Manifest: &specs.Manifest{},
+				ImageConfig: &specs.Image{
+					Created: &created,
+					Config: specs.ImageConfig{
+						ExposedPorts: map[string]struct{}{"80/tcp": {}, "443/tcp": {}},
+					},
+				},
+				Digest: "sha256:1234",
+			},
+			true,
+		},
+		{
+			"manifestConfig (unset created)",
+			"!has(kw.oci.image('image:latest').manifestConfig().config.created) && type(kw.oci.image('image:latest').manifestConfig()) == kw.oci.ManifestConfigResponse && type(kw.oci.image('image:latest').manifestConfig().config) == kw.oci.ImageConfig",
+			"v1/oci_manifest_config",
+			"image:latest",
+			manifestCapConfig.OciImageManifestAndConfigResponse{
+				Manifest:    &specs.Manifest{},
+				ImageConfig: &specs.Image{},
+				Digest:      "sha256:1234",
+			},
+			true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -71,7 +131,7 @@ func TestOCI(t *testing.T) {
 			mockWapcClient := &mocks.MockWapcClient{}
 			mockWapcClient.On("HostCall", "kubewarden", "oci", test.expectedOperation, expectedRequest).Return(response, nil)
 
-			host.Client = mockWapcClient
+			setHostClient(t, mockWapcClient)
 
 			env, err := cel.NewEnv(
 				OCI(),
@@ -94,3 +154,328 @@ func TestOCI(t *testing.T) {
 		})
 	}
 }
+
+func TestOCITypeChecking(t *testing.T) {
+	env, err := cel.NewEnv(
+		OCI(),
+	)
+	require.NoError(t, err)
+
+	for expression, expectedError := range map[string]string{
+		"kw.oci.image('image:latest').manifest().image.layerz":                    "undefined field 'layerz'",
+		"kw.oci.image('image:latest').manifest().index.manifests[0].platform.cpu": "undefined field 'cpu'",
+		"kw.oci.image('image:latest').manifestConfig().config.config.User == 0":   "found no matching overload for '_==_' applied to '(string, int)'",
+	} {
+		_, issues := env.Compile(expression)
+		require.ErrorContains(t, issues.Err(), expectedError, expression)
+	}
+}
+
+func TestOCIParseReference(t *testing.T) {
+	const digest = "sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+
+	tests := []struct {
+		image              string
+		expectedRegistry   string
+		expectedRepository string
+		expectedTag        string
+		expectedDigest     string
+		expectedNormalized string
+	}{
+		{"nginx", "docker.io", "library/nginx", "", "", "docker.io/library/nginx:latest"},
+		{"nginx:1.27", "docker.io", "library/nginx", "1.27", "", "docker.io/library/nginx:1.27"},
+		{"bitnami/redis:7.2", "docker.io", "bitnami/redis", "7.2", "", "docker.io/bitnami/redis:7.2"},
+		{"docker.io/nginx", "docker.io", "library/nginx", "", "", "docker.io/library/nginx:latest"},
+		{"index.docker.io/bitnami/redis", "docker.io", "bitnami/redis", "", "", "docker.io/bitnami/redis:latest"},
+		{"ghcr.io/kubewarden/policy-server:v1.0.0", "ghcr.io", "kubewarden/policy-server", "v1.0.0", "", "ghcr.io/kubewarden/policy-server:v1.0.0"},
+		{"localhost/app", "localhost", "app", "", "", "localhost/app:latest"},
+		{"localhost:5000/team/app:dev", "localhost:5000", "team/app", "dev", "", "localhost:5000/team/app:dev"},
+		{"[::1]:5000/app", "[::1]:5000", "app", "", "", "[::1]:5000/app:latest"},
+		{"nginx@" + digest, "docker.io", "library/nginx", "", digest, "docker.io/library/nginx@" + digest},
+		{"registry.example.com/app:1.0@" + digest, "registry.example.com", "app", "1.0", digest, "registry.example.com/app:1.0@" + digest},
+	}
+
+	env, err := cel.NewEnv(
+		OCI(),
+	)
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			envWithImage, err := env.Extend(cel.Variable("image", cel.StringType))
+			require.NoError(t, err)
+
+			ast, issues := envWithImage.Compile(`
+				[
+					kw.oci.parseReference(image).registry(),
+					kw.oci.parseReference(image).repository(),
+					kw.oci.parseReference(image).tag(),
+					kw.oci.parseReference(image).digest(),
+					kw.oci.parseReference(image).normalized(),
+					string(kw.oci.parseReference(image).isDigestPinned())
+				]`)
+			require.Empty(t, issues)
+
+			prog, err := envWithImage.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{"image": test.image})
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf([]string{}))
+			require.NoError(t, err)
+
+			require.Equal(t, []string{
+				test.expectedRegistry,
+				test.expectedRepository,
+				test.expectedTag,
+				test.expectedDigest,
+				test.expectedNormalized,
+				strconv.FormatBool(test.expectedDigest != ""),
+			}, result)
+		})
+	}
+}
+
+func TestOCIParseReferenceErrors(t *testing.T) {
+	tests := []struct {
+		image         string
+		expectedError string
+	}{
+		{"", "invalid reference format"},
+		{"nginx:", "invalid reference format"},
+		{"https://ghcr.io/app", "invalid reference format"},
+		{"ghcr.io/Kubewarden/app", "repository name must be lowercase"},
+		{"nginx@sha256:1234", "invalid reference format"},
+		{"nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c", "invalid checksum digest length"},
+		{"0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31", "repository name cannot be a 64-byte hexadecimal string"},
+	}
+
+	env, err := cel.NewEnv(
+		OCI(),
+		cel.Variable("image", cel.StringType),
+	)
+	require.NoError(t, err)
+
+	ast, issues := env.Compile("kw.oci.parseReference(image).normalized()")
+	require.Empty(t, issues)
+
+	prog, err := env.Program(ast)
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			_, _, err := prog.Eval(map[string]interface{}{"image": test.image})
+			require.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestOCIPlatforms(t *testing.T) {
+	index, err := json.Marshal(specs.Index{
+		MediaType: specs.MediaTypeImageIndex,
+		Manifests: []specs.Descriptor{
+			{Digest: "sha256:1111", Platform: &specs.Platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: "sha256:2222", Platform: &specs.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+			{Digest: "sha256:5555", Platform: &specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+			{Digest: "sha256:3333", Platform: &specs.Platform{OS: "unknown", Architecture: "unknown"}},
+		},
+	})
+	require.NoError(t, err)
+	imageManifest, err := json.Marshal(specs.Manifest{MediaType: specs.MediaTypeImageManifest})
+	require.NoError(t, err)
+	arm64Config, err := json.Marshal(manifestCapConfig.OciImageManifestAndConfigResponse{
+		Manifest:    &specs.Manifest{MediaType: specs.MediaTypeImageManifest},
+		ImageConfig: &specs.Image{Platform: specs.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, Config: specs.ImageConfig{User: "1000"}},
+		Digest:      "sha256:2222",
+	})
+	require.NoError(t, err)
+	amd64Config, err := json.Marshal(manifestCapConfig.OciImageManifestAndConfigResponse{
+		Manifest:    &specs.Manifest{MediaType: specs.MediaTypeImageManifest},
+		ImageConfig: &specs.Image{Platform: specs.Platform{OS: "linux", Architecture: "amd64"}},
+		Digest:      "sha256:4444",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		expression     string
+		expectedResult any
+		expectedError  string
+	}{
+		{
+			name:           "platforms of an index",
+			expression:     "kw.oci.image('nginx:latest').platforms()",
+			expectedResult: []string{"linux/amd64", "linux/arm64", "linux/arm/v7"},
+		},
+		{
+			name:           "arm64 platform without the default variant",
+			expression:     "'linux/arm64' in kw.oci.image('nginx:latest').platforms() && kw.oci.image('nginx:latest').manifestFor('linux/arm64').digest == 'sha256:2222'",
+			expectedResult: true,
+		},
+		{
+			name:           "platforms of a single platform image",
+			expression:     "kw.oci.image('ghcr.io/app:1.0').platforms()",
+			expectedResult: []string{"linux/amd64"},
+		},
+		{
+			name:           "manifest for a platform of an index",
+			expression:     "kw.oci.image('nginx:latest').manifestFor('linux/arm64').config.config.User",
+			expectedResult: "1000",
+		},
+		{
+			name:           "manifest for a platform with variant",
+			expression:     "kw.oci.image('nginx:latest').manifestFor('linux/arm64/v8').digest",
+			expectedResult: "sha256:2222",
+		},
+		{
+			name:           "manifest for a single platform image",
+			expression:     "kw.oci.image('ghcr.io/app:1.0').manifestFor('linux/amd64').digest",
+			expectedResult: "sha256:4444",
+		},
+		{
+			name:          "unsupported platform of an index",
+			expression:    "kw.oci.image('nginx:latest').manifestFor('linux/s390x')",
+			expectedError: "image 'nginx:latest' does not support the platform 'linux/s390x'",
+		},
+		{
+			name:          "unsupported platform of a single platform image",
+			expression:    "kw.oci.image('ghcr.io/app:1.0').manifestFor('linux/arm64')",
+			expectedError: "image 'ghcr.io/app:1.0' does not support the platform 'linux/arm64'",
+		},
+		{
+			name:          "invalid platform",
+			expression:    "kw.oci.image('nginx:latest').manifestFor('arm64')",
+			expectedError: "invalid platform 'arm64': the platform must be in the form 'os/architecture[/variant]'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockWapcClient := &mocks.MockWapcClient{}
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest", []byte(`"nginx:latest"`)).Return(index, nil)
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest_config", []byte(`"docker.io/library/nginx@sha256:2222"`)).Return(arm64Config, nil)
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest", []byte(`"ghcr.io/app:1.0"`)).Return(imageManifest, nil)
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest_config", []byte(`"ghcr.io/app:1.0"`)).Return(amd64Config, nil)
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				OCI(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+}
+
+func TestOCIImageConfigHelpers(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newResponse := func(image *specs.Image) []byte {
+		response, err := json.Marshal(manifestCapConfig.OciImageManifestAndConfigResponse{
+			Manifest: &specs.Manifest{
+				MediaType: specs.MediaTypeImageManifest,
+				Layers: []specs.Descriptor{
+					{Digest: "sha256:1111", Size: 1000},
+					{Digest: "sha256:2222", Size: 234},
+				},
+			},
+			ImageConfig: image,
+			Digest:      "sha256:3333",
+		})
+		require.NoError(t, err)
+
+		return response
+	}
+	rootImage := newResponse(&specs.Image{})
+	userImage := newResponse(&specs.Image{
+		Created: &created,
+		Config: specs.ImageConfig{
+			User:         "1000:1000",
+			ExposedPorts: map[string]struct{}{"80/tcp": {}, "443/tcp": {}},
+			Labels:       map[string]string{"org.opencontainers.image.source": "https://github.com/kubewarden/cel-policy"},
+		},
+	})
+
+	tests := []struct {
+		name           string
+		expression     string
+		expectedResult any
+	}{
+		{"runsAsRoot without user", "kw.oci.image('root:latest').manifestConfig().runsAsRoot()", true},
+		{"runsAsRoot with user", "kw.oci.image('user:latest').manifestConfig().runsAsRoot()", false},
+		{"createdAt", "kw.oci.image('user:latest').manifestConfig().createdAt() == timestamp('2024-01-02T03:04:05Z')", true},
+		{"createdAt without creation time", "kw.oci.image('root:latest').manifestConfig().createdAt() == timestamp('1970-01-01T00:00:00Z')", true},
+		{"labels", "kw.oci.image('user:latest').manifestConfig().labels()['org.opencontainers.image.source']", "https://github.com/kubewarden/cel-policy"},
+		{"labels without labels", "kw.oci.image('root:latest').manifestConfig().labels()", map[string]string{}},
+		{"exposedPorts", "kw.oci.image('user:latest').manifestConfig().exposedPorts()", []string{"443/tcp", "80/tcp"}},
+		{"exposedPorts without ports", "kw.oci.image('root:latest').manifestConfig().exposedPorts()", []string{}},
+		{"totalSize", "kw.oci.image('user:latest').manifestConfig().totalSize()", int64(1234)},
+		{"layerCount", "kw.oci.image('user:latest').manifestConfig().layerCount()", int64(2)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockWapcClient := &mocks.MockWapcClient{}
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest_config", []byte(`"root:latest"`)).Return(rootImage, nil)
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v1/oci_manifest_config", []byte(`"user:latest"`)).Return(userImage, nil)
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				OCI(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+}
+
+func TestOCIRunsAsRoot(t *testing.T) {
+	tests := map[string]bool{
+		"":          true,
+		"0":         true,
+		"root":      true,
+		"0:0":       true,
+		"root:1000": true,
+		"1000":      false,
+		"1000:0":    false,
+		"nginx":     false,
+	}
+
+	for user, expected := range tests {
+		t.Run(user, func(t *testing.T) {
+			require.Equal(t, expected, runsAsRoot(user))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxOwnerDepth is the maximum number of owners followed when looking for the
+// top-level controller of an object.
+const maxOwnerDepth = 10
+
+func owners(arg ref.Val) ref.Val {
+	mapper, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	chain, err := ownerChain(mapper)
+	if err != nil {
+		return types.NewErr("cannot get owners: %s", err)
+	}
+
+	return types.NewDynamicList(types.DefaultTypeAdapter, chain)
+}
+
+func rootOwner(arg ref.Val) ref.Val {
+	mapper, ok := arg.(traits.Mapper)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	chain, err := ownerChain(mapper)
+	if err != nil {
+		return types.NewErr("cannot get root owner: %s", err)
+	}
+
+	if len(chain) == 0 {
+		return arg
+	}
+
+	return types.DefaultTypeAdapter.NativeToValue(chain[len(chain)-1])
+}
+
+// ownerChain returns the controllers of the object, from its direct controller
+// up to the top-level one.
+func ownerChain(mapper traits.Mapper) ([]any, error) {
+	native, err := mapper.ConvertToNative(reflect.TypeOf(&structpb.Struct{}))
+	if err != nil {
+		return nil, err
+	}
+	structValue, ok := native.(*structpb.Struct)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", native)
+	}
+	object := structValue.AsMap()
+
+	visited := map[string]bool{objectKey(object): true}
+	var chain []any
+	for {
+		ownerRef, found := controllerRef(object)
+		if !found {
+			return chain, nil
+		}
+
+		if len(chain) == maxOwnerDepth {
+			return nil, fmt.Errorf("more than %d owners", maxOwnerDepth)
+		}
+
+		owner, err := getOwner(ownerRef, object)
+		if err != nil {
+			return nil, err
+		}
+
+		key := objectKey(owner)
+		if visited[key] {
+			return nil, fmt.Errorf("cyclic owner reference to %s", key)
+		}
+		visited[key] = true
+
+		chain = append(chain, owner)
+		object = owner
+	}
+}
+
+// controllerRef returns the owner reference of the object having `controller: true`.
+func controllerRef(object map[string]any) (map[string]any, bool) {
+	ownerRefs, _ := metadataField(object, "ownerReferences").([]any)
+	for _, ownerRef := range ownerRefs {
+		reference, ok := ownerRef.(map[string]any)
+		if !ok {
+			continue
+		}
+		if controller, _ := reference["controller"].(bool); controller {
+			return reference, true
+		}
+	}
+
+	return nil, false
+}
+
+// getOwner returns the owner of the object referenced by the owner reference.
+// The owner of a namespaced object is either in the same namespace or cluster-scoped,
+// like the Node controlling a mirror Pod: the owner is fetched without namespace
+// when it is not found in the namespace of the object.
+func getOwner(ownerRef map[string]any, object map[string]any) (map[string]any, error) {
+	apiVersion, _ := ownerRef["apiVersion"].(string)
+	kind, _ := ownerRef["kind"].(string)
+	name, _ := ownerRef["name"].(string)
+	if apiVersion == "" || kind == "" || name == "" {
+		return nil, errors.New("owner reference must have apiVersion, kind and name")
+	}
+
+	request := kubernetes.GetResourceRequest{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+	}
+	if namespace, _ := metadataField(object, "namespace").(string); namespace != "" {
+		request.Namespace = &namespace
+	}
+
+	responseBytes, err := kubernetes.GetResource(&host, request)
+	if err != nil && request.Namespace != nil {
+		request.Namespace = nil
+		if clusterResponseBytes, clusterErr := kubernetes.GetResource(&host, request); clusterErr == nil {
+			responseBytes, err = clusterResponseBytes, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s %s: %w", kind, name, err)
+	}
+
+	var owner map[string]any
+	if err = json.Unmarshal(responseBytes, &owner); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal %s %s: %w", kind, name, err)
+	}
+
+	return owner, nil
+}
+
+// objectKey identifies an object by its UID, or by its kind and name when the
+// UID is not set, as it happens for objects being created.
+func objectKey(object map[string]any) string {
+	if uid, _ := metadataField(object, "uid").(string); uid != "" {
+		return uid
+	}
+
+	kind, _ := object["kind"].(string)
+	namespace, _ := metadataField(object, "namespace").(string)
+	name, _ := metadataField(object, "name").(string)
+
+	return strings.Join([]string{kind, namespace, name}, "/")
+}
+
+func metadataField(object map[string]any, field string) any {
+	metadata, _ := object["metadata"].(map[string]any)
+
+	return metadata[field]
+}
@@ -0,0 +1,143 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func ownedObject(apiVersion, kind, name, uid, ownerAPIVersion, ownerKind, ownerName string) string {
+	ownerReferences := "[]"
+	if ownerName != "" {
+		ownerReferences = fmt.Sprintf(
+			`[{"apiVersion": "v1", "kind": "ConfigMap", "name": "unrelated"}, {"apiVersion": %q, "kind": %q, "name": %q, "controller": true}]`,
+			ownerAPIVersion, ownerKind, ownerName)
+	}
+
+	return fmt.Sprintf(`{"apiVersion": %q, "kind": %q, "metadata": {"name": %q, "namespace": "default", "uid": %q, "labels": {"app": %q}, "ownerReferences": %s}}`,
+		apiVersion, kind, name, uid, name, ownerReferences)
+}
+
+func TestKubernetesOwners(t *testing.T) {
+	pod := ownedObject("v1", "Pod", "nginx-6d4cf56db6-x2tqf", "", "apps/v1", "ReplicaSet", "nginx-6d4cf56db6")
+	mirrorPod := ownedObject("v1", "Pod", "kube-apiserver-node-1", "", "v1", "Node", "node-1")
+	node := `{"apiVersion": "v1", "kind": "Node", "metadata": {"name": "node-1", "uid": "3"}}`
+	replicaSet := ownedObject("apps/v1", "ReplicaSet", "nginx-6d4cf56db6", "1", "apps/v1", "Deployment", "nginx")
+	deployment := ownedObject("apps/v1", "Deployment", "nginx", "2", "", "", "")
+	cyclicDeployment := ownedObject("apps/v1", "Deployment", "nginx", "2", "apps/v1", "ReplicaSet", "nginx-6d4cf56db6")
+
+	tests := []struct {
+		name           string
+		object         string
+		resources      map[string]string
+		expression     string
+		expectedResult any
+		expectedError  string
+	}{
+		{
+			name:           "owners",
+			object:         pod,
+			resources:      map[string]string{"ReplicaSet": replicaSet, "Deployment": deployment},
+			expression:     "kw.k8s.owners(object).map(o, o.kind)",
+			expectedResult: []any{"ReplicaSet", "Deployment"},
+		},
+		{
+			name:           "root owner",
+			object:         pod,
+			resources:      map[string]string{"ReplicaSet": replicaSet, "Deployment": deployment},
+			expression:     "kw.k8s.rootOwner(object).metadata.labels.app",
+			expectedResult: "nginx",
+		},
+		{
+			name:           "no owners",
+			object:         deployment,
+			expression:     "kw.k8s.owners(object).size()",
+			expectedResult: int64(0),
+		},
+		{
+			name:           "root owner of an object without owners",
+			object:         deployment,
+			expression:     "kw.k8s.rootOwner(object).metadata.name",
+			expectedResult: "nginx",
+		},
+		{
+			name:           "cluster-scoped owner",
+			object:         mirrorPod,
+			resources:      map[string]string{"Node": node},
+			expression:     "kw.k8s.owners(object).map(o, o.kind + '/' + o.metadata.name)",
+			expectedResult: []any{"Node/node-1"},
+		},
+		{
+			name:          "missing owner",
+			object:        pod,
+			resources:     map[string]string{"ReplicaSet": replicaSet},
+			expression:    "kw.k8s.rootOwner(object)",
+			expectedError: "cannot get root owner: cannot get Deployment nginx: not found",
+		},
+		{
+			name:          "cyclic owner references",
+			object:        pod,
+			resources:     map[string]string{"ReplicaSet": replicaSet, "Deployment": cyclicDeployment},
+			expression:    "kw.k8s.owners(object)",
+			expectedError: "cannot get owners: cyclic owner reference to 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the owners are namespaced, except for the Node which is only found without namespace
+			mockWapcClient := &mocks.MockWapcClient{}
+			for _, owner := range []struct{ apiVersion, kind, name string }{
+				{"apps/v1", "ReplicaSet", "nginx-6d4cf56db6"},
+				{"apps/v1", "Deployment", "nginx"},
+				{"v1", "Node", "node-1"},
+			} {
+				for _, namespace := range []*string{stringPtr("default"), nil} {
+					request, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: owner.apiVersion, Kind: owner.kind, Name: owner.name, Namespace: namespace})
+					require.NoError(t, err)
+
+					if resource, found := test.resources[owner.kind]; found && (namespace != nil) == (owner.kind != "Node") {
+						mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return([]byte(resource), nil)
+					} else {
+						mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(nil, errors.New("not found"))
+					}
+				}
+			}
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				Kubernetes(),
+				cel.Variable("object", cel.DynType),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			var object map[string]any
+			require.NoError(t, json.Unmarshal([]byte(test.object), &object))
+
+			val, _, err := prog.Eval(map[string]any{"object": object})
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+}
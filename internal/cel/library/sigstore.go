@@ -2,9 +2,13 @@
 package library
 
 import (
+	"errors"
+	"slices"
+
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci"
 	verify "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/verify_v2"
 )
@@ -23,6 +27,17 @@ const sigstoreVerifierArgsCount = 3
 //
 //	kw.sigstore.image('image:latest') // returns a verifier builder for the 'image:latest' image
 //
+// images
+//
+// Returns a verifier builder object that applies the same verifier to every image of the list.
+// The images are verified with `verifyAll()`.
+//
+//	kw.sigstore.images(<list<string>>) <VerifierBuilder>
+//
+// Examples:
+//
+//	kw.sigstore.images(['image:latest', 'other:latest']) // returns a verifier builder for the 'image:latest' and 'other:latest' images
+//
 // annotation
 //
 // Adds an annotation to the verifier builder.
@@ -56,6 +71,23 @@ const sigstoreVerifierArgsCount = 3
 //
 //	kw.sigstore.image('image:latest').keyless('issuer1', 'subject1').keyless('issuer2', 'subject2') // returns a verifier that verifies the signature of the 'image:latest' image using keyless signing with the keyless info 'issuer1'='subject1' and 'issuer2'='subject2'
 //
+// threshold
+//
+// Sets the number of public keys or keyless identities that must have signed the image.
+// Each key or identity is verified separately, and the image is trusted when at least the given number of them signed it.
+// The threshold must be between 1 and the number of distinct keys or identities added so far:
+// a key or an identity added more than once is counted once.
+// The keys, or the `issuer=subject` identities, that signed the image are returned by the `signers()` method of the response.
+// When the threshold is not reached and the host failed to verify some of the keys or identities, e.g. because the registry
+// cannot be reached, the verification fails with the errors of the host, like the verification without a threshold.
+//
+//	<PubKeysVerifier>.threshold(<int>) <PubKeysVerifier>
+//	<KeylessVerifier>.threshold(<int>) <KeylessVerifier>
+//
+// Examples:
+//
+//	kw.sigstore.image('image:latest').pubKey('pubkey1').pubKey('pubkey2').pubKey('pubkey3').threshold(2) // returns a verifier that trusts the 'image:latest' image if at least two of the public keys signed it
+//
 // keylessPrefix
 //
 // Builds a verifier that verifies the signature of an image using keyless signing.
@@ -93,6 +125,17 @@ const sigstoreVerifierArgsCount = 3
 //
 //	kw.sigstore.image('image:latest').certificate('certificate') // returns a verifier that verifies sigstore signatures of the 'image:latest' image using the provided certificate
 //
+// trustRoot
+//
+// Builds a verifier that verifies the signature of an image using a trust root declared in the `trustRoots` settings.
+// The unknown trust roots are reported when the settings are validated.
+//
+//	<VerifierBuilder>.trustRoot(<string>) <TrustRootVerifier>
+//
+// Examples:
+//
+//	kw.sigstore.image('image:latest').trustRoot('release-keys') // returns a verifier that verifies the signature of the 'image:latest' image using the 'release-keys' trust root
+//
 // certificateChain
 //
 // Adds a certificate to the certificate verifier's chain.
@@ -120,12 +163,15 @@ const sigstoreVerifierArgsCount = 3
 //
 // Verifies the signature of an image using the verifier.
 // Returns a Response object with the methods `isTrusted()` and `digest()` to check the trust of the signature and get the digest of the image respectively.
+// When a threshold is set, the method `signers()` returns the public keys or the keyless identities, as `issuer=subject`, that signed the image,
+// and the digest is empty when the threshold is not reached.
 //
 //	<PubKeysVerifier>.verify() <Response>
 //	<KeylessVerifier>.verify() <Response>
 //	<KeylessPrefixVerifier>.verify() <Response>
 //	<GitHubActionVerifier>.verify() <Response>
 //	<CertificateVerifier>.verify() <Response>
+//	<TrustRootVerifier>.verify() <Response>
 //
 // Examples:
 //
@@ -134,19 +180,60 @@ const sigstoreVerifierArgsCount = 3
 //	kw.sigstore.image('image:latest').keylessPrefix('issuer', 'https://example.com/').verify().isTrusted() // returns whether the signature of the 'image:latest' image using keyless signing with the keyless prefix info 'issuer'='https://example.com/' is trusted
 //	kw.sigstore.image('image:latest').github('owner', 'repo').verify().digest() // returns the digest of the 'image:latest' image using keyless signatures made via Github Actions with the owner 'owner' and the repo 'repo'
 //	kw.sigstore.image('image:latest').certificate('certificate').certificateChain('certificate1').verify().isTrusted() // returns whether the signature of the 'image:latest' image using the provided certificate is trusted
-func Sigstore() cel.EnvOption {
-	return cel.Lib(&sigstoreLib{})
+//	kw.sigstore.image('image:latest').keyless('issuer', 'alice@example.com').keyless('issuer', 'bob@example.com').threshold(1).verify().signers() // returns the identities, among 'issuer=alice@example.com' and 'issuer=bob@example.com', that signed the 'image:latest' image
+//
+// verifyAll
+//
+// Verifies the signatures of all the images of the verifier, each image being verified once.
+// Returns a list of Response objects, in the order of the images, with the method `image()` returning the verified image.
+// An image whose verification fails is not trusted and has an empty digest, so that the untrusted images can be reported.
+// The reason of the failure, like a missing signature or a registry that cannot be reached, is returned by `error()`.
+//
+//	<PubKeysVerifier>.verifyAll() <list<Response>>
+//	<KeylessVerifier>.verifyAll() <list<Response>>
+//	<KeylessPrefixVerifier>.verifyAll() <list<Response>>
+//	<GitHubActionVerifier>.verifyAll() <list<Response>>
+//	<CertificateVerifier>.verifyAll() <list<Response>>
+//	<TrustRootVerifier>.verifyAll() <list<Response>>
+//
+// Examples:
+//
+//	kw.sigstore.images(['image:latest', 'other:latest']).pubKey('pubkey').verifyAll().all(r, r.isTrusted()) // returns whether the signatures of all the images using the public key 'pubkey' are trusted
+//	kw.sigstore.images(['image:latest', 'other:latest']).pubKey('pubkey').verifyAll().filter(r, !r.isTrusted()).map(r, r.image()) // returns the images whose signature is not trusted
+//
+// error
+//
+// Returns the reason why the verification of the image failed, an empty string when the verification succeeded.
+// Only the responses of `verifyAll()` have an error, `verify()` fails the evaluation instead.
+//
+//	<Response>.error() <string>
+//
+// Examples:
+//
+//	kw.sigstore.images(['image:latest', 'other:latest']).pubKey('pubkey').verifyAll().filter(r, r.error() != '').map(r, r.image() + ': ' + r.error()) // returns the images that failed the verification, with the reason
+//
+// The trust roots are the named verifier configurations of the `trustRoots` settings.
+func Sigstore(trustRoots ...SigstoreTrustRoot) cel.EnvOption {
+	lib := &sigstoreLib{trustRoots: make(map[string]SigstoreTrustRoot, len(trustRoots))}
+	for _, trustRoot := range trustRoots {
+		lib.trustRoots[trustRoot.Name] = trustRoot
+	}
+
+	return cel.Lib(lib)
 }
 
-type sigstoreLib struct{}
+type sigstoreLib struct {
+	trustRoots map[string]SigstoreTrustRoot
+}
 
 func (*sigstoreLib) LibraryName() string {
 	return "kw.sigstore"
 }
 
 //nolint:funlen // Splitting this function would make it harder to read
-func (*sigstoreLib) CompileOptions() []cel.EnvOption {
+func (l *sigstoreLib) CompileOptions() []cel.EnvOption {
 	return []cel.EnvOption{
+		cel.ASTValidators(sigstoreTrustRootValidator{trustRoots: l.trustRoots}),
 		cel.Function("kw.sigstore.image",
 			cel.Overload("kw_sigstore_image",
 				[]*cel.Type{cel.StringType},
@@ -154,6 +241,13 @@ func (*sigstoreLib) CompileOptions() []cel.EnvOption {
 				cel.UnaryBinding(sigstoreImage),
 			),
 		),
+		cel.Function("kw.sigstore.images",
+			cel.Overload("kw_sigstore_images",
+				[]*cel.Type{cel.ListType(cel.StringType)},
+				sigstoreVerifierBuilderType,
+				cel.UnaryBinding(sigstoreImages),
+			),
+		),
 		cel.Function("annotation",
 			cel.MemberOverload("kw_sigstore_verifier_builder_annotation",
 				[]*cel.Type{sigstoreVerifierBuilderType, cel.StringType, cel.StringType},
@@ -185,6 +279,18 @@ func (*sigstoreLib) CompileOptions() []cel.EnvOption {
 				cel.FunctionBinding(sigstoreKeylessVerifierKeyless),
 			),
 		),
+		cel.Function("threshold",
+			cel.MemberOverload("kw_sigstore_pub_keys_verifier_threshold",
+				[]*cel.Type{sigstorePubKeysVerifierType, cel.IntType},
+				sigstorePubKeysVerifierType,
+				cel.BinaryBinding(sigstorePubKeysVerifierThreshold),
+			),
+			cel.MemberOverload("kw_sigstore_keyless_verifier_threshold",
+				[]*cel.Type{sigstoreKeylessVerifierType, cel.IntType},
+				sigstoreKeylessVerifierType,
+				cel.BinaryBinding(sigstoreKeylessVerifierThreshold),
+			),
+		),
 		cel.Function("keylessPrefix",
 			cel.MemberOverload("kw_sigstore_verifier_builder_keyless_prefix",
 				[]*cel.Type{sigstoreVerifierBuilderType, cel.StringType, cel.StringType},
@@ -216,6 +322,13 @@ func (*sigstoreLib) CompileOptions() []cel.EnvOption {
 				cel.BinaryBinding(sigstoreVerifierBuilderCertificate),
 			),
 		),
+		cel.Function("trustRoot",
+			cel.MemberOverload("kw_sigstore_verifier_builder_trust_root",
+				[]*cel.Type{sigstoreVerifierBuilderType, cel.StringType},
+				sigstoreTrustRootVerifierType,
+				cel.BinaryBinding(l.verifierBuilderTrustRoot),
+			),
+		),
 		cel.Function("certificateChain",
 			cel.MemberOverload("kw_sigstore_certificate_verifier_certificate_chain",
 				[]*cel.Type{sigstoreCertificateVerifierType, cel.StringType},
@@ -234,27 +347,64 @@ func (*sigstoreLib) CompileOptions() []cel.EnvOption {
 			cel.MemberOverload("kw_sigstore_pub_keys_verifier_verify",
 				[]*cel.Type{sigstorePubKeysVerifierType},
 				sigstoreResponseType,
-				cel.UnaryBinding(sigstorePubKeysVerifierVerify),
+				cel.UnaryBinding(sigstoreVerifierVerify),
 			),
 			cel.MemberOverload("kw_sigstore_keyless_verifier_verify",
 				[]*cel.Type{sigstoreKeylessVerifierType},
 				sigstoreResponseType,
-				cel.UnaryBinding(sigstoreKeylessVerifierVerify),
+				cel.UnaryBinding(sigstoreVerifierVerify),
 			),
 			cel.MemberOverload("kw_sigstore_keyless_prefix_verifier_verify",
 				[]*cel.Type{sigstoreKeylessPrefixVerifierType},
 				sigstoreResponseType,
-				cel.UnaryBinding(sigstoreKeylessPrefixVerifierVerify),
+				cel.UnaryBinding(sigstoreVerifierVerify),
 			),
 			cel.MemberOverload("kw_sigstore_github_verifier_verify",
 				[]*cel.Type{sigstoreGitHubActionVerifierType},
 				sigstoreResponseType,
-				cel.UnaryBinding(sigstoreKeylessGitHubActionsVerifierVerify),
+				cel.UnaryBinding(sigstoreVerifierVerify),
 			),
 			cel.MemberOverload("kw_sigstore_certificate_verifier_verify",
 				[]*cel.Type{sigstoreCertificateVerifierType},
 				sigstoreResponseType,
-				cel.UnaryBinding(sigstoreCertificateVerifierVerify),
+				cel.UnaryBinding(sigstoreVerifierVerify),
+			),
+			cel.MemberOverload("kw_sigstore_trust_root_verifier_verify",
+				[]*cel.Type{sigstoreTrustRootVerifierType},
+				sigstoreResponseType,
+				cel.UnaryBinding(sigstoreVerifierVerify),
+			),
+		),
+		cel.Function("verifyAll",
+			cel.MemberOverload("kw_sigstore_pub_keys_verifier_verify_all",
+				[]*cel.Type{sigstorePubKeysVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
+			),
+			cel.MemberOverload("kw_sigstore_keyless_verifier_verify_all",
+				[]*cel.Type{sigstoreKeylessVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
+			),
+			cel.MemberOverload("kw_sigstore_keyless_prefix_verifier_verify_all",
+				[]*cel.Type{sigstoreKeylessPrefixVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
+			),
+			cel.MemberOverload("kw_sigstore_github_verifier_verify_all",
+				[]*cel.Type{sigstoreGitHubActionVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
+			),
+			cel.MemberOverload("kw_sigstore_certificate_verifier_verify_all",
+				[]*cel.Type{sigstoreCertificateVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
+			),
+			cel.MemberOverload("kw_sigstore_trust_root_verifier_verify_all",
+				[]*cel.Type{sigstoreTrustRootVerifierType},
+				cel.ListType(sigstoreResponseType),
+				cel.UnaryBinding(sigstoreVerifierVerifyAll),
 			),
 		),
 		cel.Function("isTrusted",
@@ -271,6 +421,27 @@ func (*sigstoreLib) CompileOptions() []cel.EnvOption {
 				cel.UnaryBinding(sigstoreResponseDigest),
 			),
 		),
+		cel.Function("signers",
+			cel.MemberOverload("kw_sigstore_response_signers",
+				[]*cel.Type{sigstoreResponseType},
+				cel.ListType(cel.StringType),
+				cel.UnaryBinding(sigstoreResponseSigners),
+			),
+		),
+		cel.Function("image",
+			cel.MemberOverload("kw_sigstore_response_image",
+				[]*cel.Type{sigstoreResponseType},
+				cel.StringType,
+				cel.UnaryBinding(sigstoreResponseImage),
+			),
+		),
+		cel.Function("error",
+			cel.MemberOverload("kw_sigstore_response_error",
+				[]*cel.Type{sigstoreResponseType},
+				cel.StringType,
+				cel.UnaryBinding(sigstoreResponseError),
+			),
+		),
 	}
 }
 
@@ -284,7 +455,29 @@ func sigstoreImage(arg ref.Val) ref.Val {
 		return types.MaybeNoSuchOverloadErr(arg)
 	}
 
-	return sigstoreVerifierBuilder{receiverOnlyObjectVal: receiverOnlyVal(sigstoreVerifierBuilderType), image: image}
+	return sigstoreVerifierBuilder{receiverOnlyObjectVal: receiverOnlyVal(sigstoreVerifierBuilderType), images: []string{image}}
+}
+
+func sigstoreImages(arg ref.Val) ref.Val {
+	lister, ok := arg.(traits.Lister)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	var images []string
+	for it := lister.Iterator(); it.HasNext() == types.True; {
+		item := it.Next()
+		image, ok := item.Value().(string)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(item)
+		}
+		// the same image is used by many containers, it is verified once
+		if !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+	}
+
+	return sigstoreVerifierBuilder{receiverOnlyObjectVal: receiverOnlyVal(sigstoreVerifierBuilderType), images: images}
 }
 
 func sigstoreVerifierBuilderAnnotation(args ...ref.Val) ref.Val {
@@ -329,7 +522,7 @@ func sigstoreVerifierBuilderPubKey(arg1, arg2 ref.Val) ref.Val {
 
 	return sigstorePubKeysVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstorePubKeysVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		pubKeys:               []string{pubKey},
 	}
@@ -351,15 +544,6 @@ func sigstorePubKeysVerifierPubKey(arg1, arg2 ref.Val) ref.Val {
 	return verifier
 }
 
-func sigstorePubKeysVerifierVerify(arg ref.Val) ref.Val {
-	verifier, ok := arg.(sigstorePubKeysVerifier)
-	if !ok {
-		return types.MaybeNoSuchOverloadErr(arg)
-	}
-
-	return verifier.verify()
-}
-
 func sigstoreVerifierBuilderKeyless(args ...ref.Val) ref.Val {
 	if len(args) != sigstoreVerifierArgsCount {
 		return types.NoSuchOverloadErr()
@@ -384,7 +568,7 @@ func sigstoreVerifierBuilderKeyless(args ...ref.Val) ref.Val {
 
 	return sigstoreKeylessVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstoreKeylessVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		keyless:               keyless,
 	}
@@ -415,15 +599,6 @@ func sigstoreKeylessVerifierKeyless(args ...ref.Val) ref.Val {
 	return verifier
 }
 
-func sigstoreKeylessVerifierVerify(arg ref.Val) ref.Val {
-	verifier, ok := arg.(sigstoreKeylessVerifier)
-	if !ok {
-		return types.MaybeNoSuchOverloadErr(arg)
-	}
-
-	return verifier.verify()
-}
-
 func sigstoreVerifierBuilderKeylessPrefix(args ...ref.Val) ref.Val {
 	if len(args) != sigstoreVerifierArgsCount {
 		return types.NoSuchOverloadErr()
@@ -448,7 +623,7 @@ func sigstoreVerifierBuilderKeylessPrefix(args ...ref.Val) ref.Val {
 
 	return sigstoreKeylessPrefixVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstoreKeylessPrefixVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		keylessPrefix:         keylessPrefix,
 	}
@@ -479,15 +654,6 @@ func sigstoreKeylessPrefixVerifierKeylessPrefix(args ...ref.Val) ref.Val {
 	return verifier
 }
 
-func sigstoreKeylessPrefixVerifierVerify(arg ref.Val) ref.Val {
-	verifier, ok := arg.(sigstoreKeylessPrefixVerifier)
-	if !ok {
-		return types.MaybeNoSuchOverloadErr(arg)
-	}
-
-	return verifier.verify()
-}
-
 func sigstoreVerifierBuilderGitHubActionOwner(arg1, arg2 ref.Val) ref.Val {
 	builder, ok := arg1.(sigstoreVerifierBuilder)
 	if !ok {
@@ -501,7 +667,7 @@ func sigstoreVerifierBuilderGitHubActionOwner(arg1, arg2 ref.Val) ref.Val {
 
 	return sigstoreGitHubActionVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstoreGitHubActionVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		owner:                 owner,
 	}
@@ -529,22 +695,13 @@ func sigstoreVerifierBuilderGitHubActionOwnerRepo(args ...ref.Val) ref.Val {
 
 	return sigstoreGitHubActionVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstoreGitHubActionVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		owner:                 owner,
 		repo:                  repo,
 	}
 }
 
-func sigstoreKeylessGitHubActionsVerifierVerify(arg ref.Val) ref.Val {
-	verifier, ok := arg.(sigstoreGitHubActionVerifier)
-	if !ok {
-		return types.MaybeNoSuchOverloadErr(arg)
-	}
-
-	return verifier.verify()
-}
-
 func sigstoreVerifierBuilderCertificate(arg1, arg2 ref.Val) ref.Val {
 	builder, ok := arg1.(sigstoreVerifierBuilder)
 	if !ok {
@@ -558,7 +715,7 @@ func sigstoreVerifierBuilderCertificate(arg1, arg2 ref.Val) ref.Val {
 
 	return sigstoreCertificateVerifier{
 		receiverOnlyObjectVal: receiverOnlyVal(sigstoreCertificateVerifierType),
-		image:                 builder.image,
+		images:                builder.images,
 		annotations:           builder.annotations,
 		certificate:           []rune(certificate),
 	}
@@ -596,13 +753,92 @@ func sigstoreCertificateVerifierRequireRekorBundle(arg1, arg2 ref.Val) ref.Val {
 	return verifier
 }
 
-func sigstoreCertificateVerifierVerify(arg ref.Val) ref.Val {
-	verifier, ok := arg.(sigstoreCertificateVerifier)
+func sigstorePubKeysVerifierThreshold(arg1, arg2 ref.Val) ref.Val {
+	verifier, ok := arg1.(sigstorePubKeysVerifier)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	threshold, failure := sigstoreThreshold(arg2, len(distinct(verifier.pubKeys)))
+	if failure != nil {
+		return failure
+	}
+
+	verifier.threshold = threshold
+
+	return verifier
+}
+
+func sigstoreKeylessVerifierThreshold(arg1, arg2 ref.Val) ref.Val {
+	verifier, ok := arg1.(sigstoreKeylessVerifier)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	threshold, failure := sigstoreThreshold(arg2, len(distinct(verifier.keyless)))
+	if failure != nil {
+		return failure
+	}
+
+	verifier.threshold = threshold
+
+	return verifier
+}
+
+// sigstoreThreshold returns the threshold, which must be between 1 and the number of distinct signers.
+func sigstoreThreshold(arg ref.Val, signers int) (int, ref.Val) {
+	threshold, ok := arg.Value().(int64)
+	if !ok {
+		return 0, types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	if threshold < 1 || threshold > int64(signers) {
+		return 0, types.NewErr("invalid threshold %d: the threshold must be between 1 and the number of signers, %d", threshold, signers)
+	}
+
+	return int(threshold), nil
+}
+
+func sigstoreVerifierVerify(arg ref.Val) ref.Val {
+	verifier, ok := arg.(sigstoreVerifier)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	images := verifier.verifiedImages()
+	if len(images) != 1 {
+		return types.NewErr("failed to verify images: verify() verifies a single image, use verifyAll() to verify %d images", len(images))
+	}
+
+	response, err := verifier.verifyImage(images[0])
+	if err != nil {
+		return types.NewErr("failed to verify image: %s", err)
+	}
+
+	return response
+}
+
+func sigstoreVerifierVerifyAll(arg ref.Val) ref.Val {
+	verifier, ok := arg.(sigstoreVerifier)
 	if !ok {
 		return types.MaybeNoSuchOverloadErr(arg)
 	}
 
-	return verifier.verify()
+	images := verifier.verifiedImages()
+	responses := make([]ref.Val, 0, len(images))
+	for _, image := range images {
+		// the host fails the verification of the images that are not signed,
+		// they are reported as not trusted instead of failing the evaluation,
+		// keeping the error to tell them apart from the registry failures
+		response, err := verifier.verifyImage(image)
+		if err != nil {
+			response = newSigstoreResponse(image, oci.VerificationResponse{})
+			response.error = err.Error()
+		}
+		responses = append(responses, response)
+	}
+
+	return types.NewRefValList(types.DefaultTypeAdapter, responses)
 }
 
 func sigstoreResponseIsTrusted(arg ref.Val) ref.Val {
@@ -623,12 +859,46 @@ func sigstoreResponseDigest(arg ref.Val) ref.Val {
 	return types.String(response.digest)
 }
 
+func sigstoreResponseImage(arg ref.Val) ref.Val {
+	response, ok := arg.(sigstoreResponse)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.String(response.image)
+}
+
+func sigstoreResponseError(arg ref.Val) ref.Val {
+	response, ok := arg.(sigstoreResponse)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.String(response.error)
+}
+
+func sigstoreResponseSigners(arg ref.Val) ref.Val {
+	response, ok := arg.(sigstoreResponse)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+
+	return types.NewStringList(types.DefaultTypeAdapter, response.signers)
+}
+
+// sigstoreVerifier is implemented by the verifiers, which verify the signature
+// of each of their images in the same way.
+type sigstoreVerifier interface {
+	verifiedImages() []string
+	verifyImage(image string) (sigstoreResponse, error)
+}
+
 var sigstoreVerifierBuilderType = cel.ObjectType("kw.sigstore.VerifierBuilder")
 
 // sigstoreVerifierBuilder is an intermediate object is used to build a specific verifier.
 type sigstoreVerifierBuilder struct {
 	receiverOnlyObjectVal
-	image       string
+	images      []string
 	annotations map[string]string
 }
 
@@ -637,22 +907,30 @@ var sigstorePubKeysVerifierType = cel.ObjectType("kw.sigstore.PubKeysVerifier")
 // sigstorePubKeysVerifier verifies the signature of an image using a set of public keys.
 type sigstorePubKeysVerifier struct {
 	receiverOnlyObjectVal
-	image       string
+	images      []string
 	annotations map[string]string
 	pubKeys     []string
+	// threshold is the number of keys that must have signed the image, each
+	// key being verified separately. All the keys are verified at once if it is 0.
+	threshold int
 }
 
-func (v *sigstorePubKeysVerifier) verify() ref.Val {
-	response, err := verify.VerifyPubKeysImage(&host, v.image, v.pubKeys, v.annotations)
-	if err != nil {
-		return types.NewErr("failed to verify image: %s", err)
-	}
+func (v sigstorePubKeysVerifier) verifiedImages() []string {
+	return v.images
+}
 
-	return sigstoreResponse{
-		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
-		isTrusted:             response.IsTrusted,
-		digest:                response.Digest,
+func (v sigstorePubKeysVerifier) verifyImage(image string) (sigstoreResponse, error) {
+	if v.threshold > 0 {
+		return verifyThreshold(image, v.threshold, v.pubKeys, func(pubKey string) (string, oci.VerificationResponse, error) {
+			response, err := verify.VerifyPubKeysImage(&host, image, []string{pubKey}, v.annotations)
+
+			return pubKey, response, err
+		})
 	}
+
+	response, err := verify.VerifyPubKeysImage(&host, image, v.pubKeys, v.annotations)
+
+	return newSigstoreResponse(image, response), err
 }
 
 var sigstoreKeylessVerifierType = cel.ObjectType("kw.sigstore.KeylessVerifier")
@@ -660,22 +938,30 @@ var sigstoreKeylessVerifierType = cel.ObjectType("kw.sigstore.KeylessVerifier")
 // sigstoreKeylessVerifier verifies the signature of an image using keyless signing.
 type sigstoreKeylessVerifier struct {
 	receiverOnlyObjectVal
-	image       string
+	images      []string
 	annotations map[string]string
 	keyless     []oci.KeylessInfo
+	// threshold is the number of identities that must have signed the image, each
+	// identity being verified separately. All the identities are verified at once if it is 0.
+	threshold int
 }
 
-func (v *sigstoreKeylessVerifier) verify() ref.Val {
-	response, err := verify.VerifyKeylessExactMatch(&host, v.image, v.keyless, v.annotations)
-	if err != nil {
-		return types.NewErr("failed to verify image: %s", err)
-	}
+func (v sigstoreKeylessVerifier) verifiedImages() []string {
+	return v.images
+}
 
-	return sigstoreResponse{
-		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
-		isTrusted:             response.IsTrusted,
-		digest:                response.Digest,
+func (v sigstoreKeylessVerifier) verifyImage(image string) (sigstoreResponse, error) {
+	if v.threshold > 0 {
+		return verifyThreshold(image, v.threshold, v.keyless, func(keyless oci.KeylessInfo) (string, oci.VerificationResponse, error) {
+			response, err := verify.VerifyKeylessExactMatch(&host, image, []oci.KeylessInfo{keyless}, v.annotations)
+
+			return keyless.Issuer + "=" + keyless.Subject, response, err
+		})
 	}
+
+	response, err := verify.VerifyKeylessExactMatch(&host, image, v.keyless, v.annotations)
+
+	return newSigstoreResponse(image, response), err
 }
 
 var sigstoreKeylessPrefixVerifierType = cel.ObjectType("kw.sigstore.KeylessPrefixVerifier")
@@ -684,22 +970,19 @@ var sigstoreKeylessPrefixVerifierType = cel.ObjectType("kw.sigstore.KeylessPrefi
 // keyless signing.
 type sigstoreKeylessPrefixVerifier struct {
 	receiverOnlyObjectVal
-	image         string
+	images        []string
 	annotations   map[string]string
 	keylessPrefix []verify.KeylessPrefixInfo
 }
 
-func (v *sigstoreKeylessPrefixVerifier) verify() ref.Val {
-	response, err := verify.VerifyKeylessPrefixMatch(&host, v.image, v.keylessPrefix, v.annotations)
-	if err != nil {
-		return types.NewErr("failed to verify image: %s", err)
-	}
+func (v sigstoreKeylessPrefixVerifier) verifiedImages() []string {
+	return v.images
+}
 
-	return sigstoreResponse{
-		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
-		isTrusted:             response.IsTrusted,
-		digest:                response.Digest,
-	}
+func (v sigstoreKeylessPrefixVerifier) verifyImage(image string) (sigstoreResponse, error) {
+	response, err := verify.VerifyKeylessPrefixMatch(&host, image, v.keylessPrefix, v.annotations)
+
+	return newSigstoreResponse(image, response), err
 }
 
 var sigstoreGitHubActionVerifierType = cel.ObjectType("kw.sigstore.GitHubActionVerifier")
@@ -708,23 +991,20 @@ var sigstoreGitHubActionVerifierType = cel.ObjectType("kw.sigstore.GitHubActionV
 // keyless signatures made via Github Actions.
 type sigstoreGitHubActionVerifier struct {
 	receiverOnlyObjectVal
-	image       string
+	images      []string
 	annotations map[string]string
 	owner       string
 	repo        string
 }
 
-func (v *sigstoreGitHubActionVerifier) verify() ref.Val {
-	response, err := verify.VerifyKeylessGithubActions(&host, v.image, v.owner, v.repo, v.annotations)
-	if err != nil {
-		return types.NewErr("failed to verify image: %s", err)
-	}
+func (v sigstoreGitHubActionVerifier) verifiedImages() []string {
+	return v.images
+}
 
-	return sigstoreResponse{
-		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
-		isTrusted:             response.IsTrusted,
-		digest:                response.Digest,
-	}
+func (v sigstoreGitHubActionVerifier) verifyImage(image string) (sigstoreResponse, error) {
+	response, err := verify.VerifyKeylessGithubActions(&host, image, v.owner, v.repo, v.annotations)
+
+	return newSigstoreResponse(image, response), err
 }
 
 var sigstoreCertificateVerifierType = cel.ObjectType("kw.sigstore.CertificateVerifier")
@@ -732,24 +1012,21 @@ var sigstoreCertificateVerifierType = cel.ObjectType("kw.sigstore.CertificateVer
 // sigstoreCertificateVerifier verifies sigstore signatures of an image using a user provided certificate.
 type sigstoreCertificateVerifier struct {
 	receiverOnlyObjectVal
-	image              string
+	images             []string
 	annotations        map[string]string
 	certificate        []rune
 	certificateChain   [][]rune
 	requireRekorBundle bool
 }
 
-func (v *sigstoreCertificateVerifier) verify() ref.Val {
-	response, err := verify.VerifyCertificate(&host, v.image, v.certificate, v.certificateChain, v.requireRekorBundle, v.annotations)
-	if err != nil {
-		return types.NewErr("failed to verify image: %s", err)
-	}
+func (v sigstoreCertificateVerifier) verifiedImages() []string {
+	return v.images
+}
 
-	return sigstoreResponse{
-		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
-		isTrusted:             response.IsTrusted,
-		digest:                response.Digest,
-	}
+func (v sigstoreCertificateVerifier) verifyImage(image string) (sigstoreResponse, error) {
+	response, err := verify.VerifyCertificate(&host, image, v.certificate, v.certificateChain, v.requireRekorBundle, v.annotations)
+
+	return newSigstoreResponse(image, response), err
 }
 
 var sigstoreResponseType = cel.ObjectType("kw.sigstore.Response")
@@ -757,6 +1034,63 @@ var sigstoreResponseType = cel.ObjectType("kw.sigstore.Response")
 // sigstoreResponse is the response object returned by the verify function.
 type sigstoreResponse struct {
 	receiverOnlyObjectVal
+	image     string
 	isTrusted bool
 	digest    string
+	// signers are the keys or the subjects that signed the image, when verifying with a threshold.
+	signers []string
+	// error is the reason why the verification failed, reported by `verifyAll()`.
+	error string
+}
+
+func newSigstoreResponse(image string, response oci.VerificationResponse) sigstoreResponse {
+	return sigstoreResponse{
+		receiverOnlyObjectVal: receiverOnlyVal(sigstoreResponseType),
+		image:                 image,
+		isTrusted:             response.IsTrusted,
+		digest:                response.Digest,
+		signers:               []string{},
+	}
+}
+
+// verifyThreshold verifies the signature of the image by each distinct signer separately,
+// so that a signer added twice is not counted twice.
+// The image is trusted when at least threshold signers signed it. The errors of
+// the host are not counted as missing signatures: they are returned when the
+// threshold is not reached, as the host may have failed to verify a signer
+// that signed the image.
+func verifyThreshold[T comparable](image string, threshold int, signers []T, verifySigner func(T) (string, oci.VerificationResponse, error)) (sigstoreResponse, error) {
+	result := newSigstoreResponse(image, oci.VerificationResponse{})
+	var errs []error
+	for _, signer := range distinct(signers) {
+		name, response, err := verifySigner(signer)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !response.IsTrusted {
+			continue
+		}
+		result.signers = append(result.signers, name)
+		result.digest = response.Digest
+	}
+	result.isTrusted = len(result.signers) >= threshold
+	if result.isTrusted {
+		return result, nil
+	}
+	result.digest = ""
+
+	return result, errors.Join(errs...)
+}
+
+// distinct returns the values without the duplicates, in the order of their first occurrence.
+func distinct[T comparable](values []T) []T {
+	result := make([]T, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+
+	return result
 }
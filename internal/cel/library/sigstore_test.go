@@ -2,10 +2,12 @@ package library
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
 
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci"
 	verify "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/verify_v2"
@@ -139,7 +141,7 @@ func TestSigstore(t *testing.T) {
 			mockWapcClient := &mocks.MockWapcClient{}
 			mockWapcClient.On("HostCall", "kubewarden", "oci", test.expectedOperation, expectedRequest).Return(response, nil)
 
-			host.Client = mockWapcClient
+			setHostClient(t, mockWapcClient)
 
 			env, err := cel.NewEnv(
 				Sigstore(),
@@ -162,3 +164,377 @@ func TestSigstore(t *testing.T) {
 		})
 	}
 }
+
+func TestSigstoreImages(t *testing.T) {
+	tests := []struct {
+		name           string
+		expression     string
+		expectedResult any
+		expectedError  string
+		expectedCalls  int
+	}{
+		{
+			"untrusted images",
+			"kw.sigstore.images(['signed:latest', 'unsigned:latest', 'signed:latest']).pubKey('key').verifyAll().filter(r, !r.isTrusted()).map(r, r.image())",
+			[]string{"unsigned:latest"},
+			"",
+			2, // the duplicated image is verified once
+		},
+		{
+			"results",
+			"kw.sigstore.images(['signed:latest', 'unsigned:latest']).pubKey('key').verifyAll().map(r, r.image() + '=' + r.digest())",
+			[]string{"signed:latest=sha256:1234", "unsigned:latest="},
+			"",
+			2,
+		},
+		{
+			"errors",
+			"kw.sigstore.images(['signed:latest', 'unsigned:latest']).pubKey('key').verifyAll().map(r, r.image() + '=' + r.error())",
+			[]string{"signed:latest=", "unsigned:latest=no signatures found"},
+			"",
+			2,
+		},
+		{
+			"single image",
+			"kw.sigstore.image('signed:latest').pubKey('key').verifyAll().all(r, r.isTrusted())",
+			true,
+			"",
+			1,
+		},
+		{
+			"no images",
+			"kw.sigstore.images([]).pubKey('key').verifyAll().size()",
+			int64(0),
+			"",
+			0,
+		},
+		{
+			"verify many images",
+			"kw.sigstore.images(['signed:latest', 'unsigned:latest']).pubKey('key').verify().isTrusted()",
+			nil,
+			"failed to verify images: verify() verifies a single image, use verifyAll() to verify 2 images",
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockWapcClient := &mocks.MockWapcClient{}
+			for _, image := range []string{"signed:latest", "unsigned:latest"} {
+				request, err := json.Marshal(verify.SigstorePubKeysVerify{
+					Image:   image,
+					PubKeys: []string{"key"},
+				})
+				require.NoError(t, err)
+
+				if image == "signed:latest" {
+					response, err := json.Marshal(oci.VerificationResponse{IsTrusted: true, Digest: "sha256:1234"})
+					require.NoError(t, err)
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(response, nil)
+				} else {
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(nil, errors.New("no signatures found"))
+				}
+			}
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				Sigstore(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			mockWapcClient.AssertNumberOfCalls(t, "HostCall", test.expectedCalls)
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+
+}
+
+func TestSigstoreTrustRoots(t *testing.T) {
+	pubKey := "-----BEGIN PUBLIC KEY-----\nkey\n-----END PUBLIC KEY-----\n"
+	certificate := "-----BEGIN CERTIFICATE-----\ncert\n-----END CERTIFICATE-----\n"
+	trustRoots := []SigstoreTrustRoot{
+		{Name: "release-keys", PubKeys: []string{pubKey}},
+		{Name: "release-workflow", Keyless: []SigstoreKeyless{{Issuer: "issuer", Subject: "subject"}}},
+		{Name: "release-prefix", KeylessPrefix: []SigstoreKeylessPrefix{{Issuer: "issuer", URLPrefix: "https://example.com/"}}},
+		{Name: "kubewarden", GitHubAction: &SigstoreGitHubAction{Owner: "kubewarden", Repo: "policy-server"}},
+		{Name: "release-certificate", Certificate: &SigstoreCertificate{Certificate: certificate, CertificateChain: []string{certificate}, RequireRekorBundle: true}},
+	}
+
+	tests := []struct {
+		name            string
+		expression      string
+		expectedRequest any
+		expectedError   string
+	}{
+		{
+			"pubKeys trust root",
+			"kw.sigstore.image('image:latest').annotation('foo', 'bar').trustRoot('release-keys').verify().isTrusted()",
+			verify.SigstorePubKeysVerify{Image: "image:latest", PubKeys: []string{pubKey}, Annotations: map[string]string{"foo": "bar"}},
+			"",
+		},
+		{
+			"keyless trust root",
+			"kw.sigstore.image('image:latest').trustRoot('release-workflow').verify().isTrusted()",
+			verify.SigstoreKeylessVerifyExact{Image: "image:latest", Keyless: []oci.KeylessInfo{{Issuer: "issuer", Subject: "subject"}}},
+			"",
+		},
+		{
+			"keylessPrefix trust root",
+			"kw.sigstore.image('image:latest').trustRoot('release-prefix').verify().isTrusted()",
+			verify.SigstoreKeylessPrefixVerify{Image: "image:latest", KeylessPrefix: []verify.KeylessPrefixInfo{{Issuer: "issuer", UrlPrefix: "https://example.com/"}}},
+			"",
+		},
+		{
+			"githubAction trust root",
+			"kw.sigstore.images(['image:latest']).trustRoot('kubewarden').verifyAll().all(r, r.isTrusted())",
+			verify.SigstoreGithubActionsVerify{Image: "image:latest", Owner: "kubewarden", Repo: "policy-server"},
+			"",
+		},
+		{
+			"certificate trust root",
+			"kw.sigstore.image('image:latest').trustRoot('release-certificate').verify().isTrusted()",
+			verify.SigstoreCertificateVerify{Image: "image:latest", Certificate: []rune(certificate), CertificateChain: [][]rune{[]rune(certificate)}, RequireRekorBundle: true},
+			"",
+		},
+		{
+			"unknown trust root",
+			"kw.sigstore.image('image:latest').trustRoot('release-' + 'signers').verify().isTrusted()",
+			nil,
+			"unknown trust root 'release-signers'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := json.Marshal(oci.VerificationResponse{IsTrusted: true, Digest: "sha256:1234"})
+			require.NoError(t, err)
+
+			expectedRequest, err := json.Marshal(test.expectedRequest)
+			require.NoError(t, err)
+
+			mockWapcClient := &mocks.MockWapcClient{}
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", expectedRequest).Return(response, nil)
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				Sigstore(trustRoots...),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, types.True, val)
+		})
+	}
+}
+
+func TestSigstoreTrustRootValidation(t *testing.T) {
+	env, err := cel.NewEnv(
+		Sigstore(SigstoreTrustRoot{Name: "release-keys", GitHubAction: &SigstoreGitHubAction{Owner: "kubewarden"}}),
+	)
+	require.NoError(t, err)
+
+	_, issues := env.Compile("kw.sigstore.image('image:latest').trustRoot('release-keys').verify().isTrusted()")
+	require.NoError(t, issues.Err())
+
+	_, issues = env.Compile("kw.sigstore.image('image:latest').trustRoot('releases').verify().isTrusted()")
+	require.ErrorContains(t, issues.Err(), "unknown trust root 'releases'")
+}
+
+func TestSigstoreThreshold(t *testing.T) {
+	tests := []struct {
+		name           string
+		expression     string
+		expectedResult any
+		expectedError  string
+	}{
+		{
+			"pubKeys threshold reached",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').pubKey('key3').threshold(2).verify().isTrusted()",
+			true,
+			"",
+		},
+		{
+			"pubKeys threshold not reached",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').pubKey('key3').threshold(3).verify().isTrusted()",
+			false,
+			"",
+		},
+		{
+			"pubKeys signers",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').pubKey('key3').threshold(3).verify().signers()",
+			[]string{"key1", "key3"},
+			"",
+		},
+		{
+			"pubKeys digest",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').threshold(1).verify().digest()",
+			"sha256:1234",
+			"",
+		},
+		{
+			"keyless signers",
+			"kw.sigstore.image('image:latest').keyless('issuer', 'alice').keyless('issuer', 'bob').threshold(1).verify().signers()",
+			[]string{"issuer=alice"},
+			"",
+		},
+		{
+			"keyless signers with the same subject",
+			"kw.sigstore.image('image:latest').keyless('issuer', 'alice').keyless('other', 'alice').threshold(2).verify().signers()",
+			[]string{"issuer=alice", "other=alice"},
+			"",
+		},
+		{
+			"duplicated pubKey",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key1').threshold(2).verify().isTrusted()",
+			nil,
+			"invalid threshold 2: the threshold must be between 1 and the number of signers, 1",
+		},
+		{
+			"duplicated pubKey counted once",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key1').pubKey('key2').threshold(2).verify().isTrusted()",
+			false,
+			"",
+		},
+		{
+			"duplicated keyless identity counted once",
+			"kw.sigstore.image('image:latest').keyless('issuer', 'alice').keyless('issuer', 'alice').keyless('issuer', 'bob').threshold(2).verify().signers()",
+			[]string{"issuer=alice"},
+			"",
+		},
+		{
+			"digest of an untrusted image",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').threshold(2).verify().digest()",
+			"",
+			"",
+		},
+		{
+			"keyless threshold not reached",
+			"kw.sigstore.images(['image:latest']).keyless('issuer', 'alice').keyless('issuer', 'bob').threshold(2).verifyAll().exists(r, r.isTrusted())",
+			false,
+			"",
+		},
+		{
+			"host error when the threshold is not reached",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').pubKey('key4').threshold(2).verify().isTrusted()",
+			nil,
+			"failed to verify image: registry unavailable",
+		},
+		{
+			"host error when the threshold is reached",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key3').pubKey('key4').threshold(2).verify().signers()",
+			[]string{"key1", "key3"},
+			"",
+		},
+		{
+			"keyless host error reported by verifyAll",
+			"kw.sigstore.images(['image:latest']).keyless('issuer', 'alice').keyless('issuer', 'carol').threshold(2).verifyAll().map(r, r.error())",
+			[]string{"registry unavailable"},
+			"",
+		},
+		{
+			"threshold greater than the signers",
+			"kw.sigstore.image('image:latest').pubKey('key1').pubKey('key2').threshold(3).verify().isTrusted()",
+			nil,
+			"invalid threshold 3: the threshold must be between 1 and the number of signers, 2",
+		},
+		{
+			"zero threshold",
+			"kw.sigstore.image('image:latest').keyless('issuer', 'alice').threshold(0).verify().isTrusted()",
+			nil,
+			"invalid threshold 0: the threshold must be between 1 and the number of signers, 1",
+		},
+	}
+
+	trusted, err := json.Marshal(oci.VerificationResponse{IsTrusted: true, Digest: "sha256:1234"})
+	require.NoError(t, err)
+	untrusted, err := json.Marshal(oci.VerificationResponse{IsTrusted: false})
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// key2 and bob did not sign the image, the host fails to verify key4 and carol
+			mockWapcClient := &mocks.MockWapcClient{}
+			for _, key := range []string{"key1", "key2", "key3", "key4"} {
+				request, err := json.Marshal(verify.SigstorePubKeysVerify{Image: "image:latest", PubKeys: []string{key}})
+				require.NoError(t, err)
+				switch key {
+				case "key2":
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(untrusted, nil)
+				case "key4":
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(nil, errors.New("registry unavailable"))
+				default:
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(trusted, nil)
+				}
+			}
+			for _, subject := range []string{"alice", "bob", "carol"} {
+				request, err := json.Marshal(verify.SigstoreKeylessVerifyExact{Image: "image:latest", Keyless: []oci.KeylessInfo{{Issuer: "issuer", Subject: subject}}})
+				require.NoError(t, err)
+				switch subject {
+				case "bob":
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(untrusted, nil)
+				case "carol":
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(nil, errors.New("registry unavailable"))
+				default:
+					mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(trusted, nil)
+				}
+			}
+			request, err := json.Marshal(verify.SigstoreKeylessVerifyExact{Image: "image:latest", Keyless: []oci.KeylessInfo{{Issuer: "other", Subject: "alice"}}})
+			require.NoError(t, err)
+			mockWapcClient.On("HostCall", "kubewarden", "oci", "v2/verify", request).Return(trusted, nil)
+
+			setHostClient(t, mockWapcClient)
+
+			env, err := cel.NewEnv(
+				Sigstore(),
+			)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(test.expression)
+			require.Empty(t, issues)
+
+			prog, err := env.Program(ast)
+			require.NoError(t, err)
+
+			val, _, err := prog.Eval(map[string]interface{}{})
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := val.ConvertToNative(reflect.TypeOf(test.expectedResult))
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedResult, result)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package library
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci"
+	verify "github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/verify_v2"
+)
+
+// SigstoreTrustRoot is a named verifier configuration, declared in the settings
+// and used by the `trustRoot` function instead of writing the keys, the
+// keyless identities or the certificates in the expressions.
+// Exactly one kind of verifier must be configured.
+type SigstoreTrustRoot struct {
+	Name          string                  `json:"name"`
+	PubKeys       []string                `json:"pubKeys,omitempty"`
+	Keyless       []SigstoreKeyless       `json:"keyless,omitempty"`
+	KeylessPrefix []SigstoreKeylessPrefix `json:"keylessPrefix,omitempty"`
+	GitHubAction  *SigstoreGitHubAction   `json:"githubAction,omitempty"`
+	Certificate   *SigstoreCertificate    `json:"certificate,omitempty"`
+}
+
+// SigstoreKeyless is the issuer and the subject of a keyless signature.
+type SigstoreKeyless struct {
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+}
+
+// SigstoreKeylessPrefix is the issuer and the URL prefix of the subject of a keyless signature.
+type SigstoreKeylessPrefix struct {
+	Issuer    string `json:"issuer"`
+	URLPrefix string `json:"urlPrefix"`
+}
+
+// SigstoreGitHubAction is the owner and the optional repository of the
+// GitHub Actions workflows signing the images.
+type SigstoreGitHubAction struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo,omitempty"`
+}
+
+// SigstoreCertificate is the PEM certificate signing the images, with its chain.
+type SigstoreCertificate struct {
+	Certificate        string   `json:"certificate"`
+	CertificateChain   []string `json:"certificateChain,omitempty"`
+	RequireRekorBundle bool     `json:"requireRekorBundle,omitempty"`
+}
+
+// Validate checks that the trust root configures exactly one kind of verifier,
+// and that the keys and the certificates are PEM encoded.
+func (r *SigstoreTrustRoot) Validate() error {
+	configured := 0
+	for _, set := range []bool{
+		len(r.PubKeys) > 0,
+		len(r.Keyless) > 0,
+		len(r.KeylessPrefix) > 0,
+		r.GitHubAction != nil,
+		r.Certificate != nil,
+	} {
+		if set {
+			configured++
+		}
+	}
+	if configured != 1 {
+		return errors.New("exactly one of pubKeys, keyless, keylessPrefix, githubAction and certificate must be set")
+	}
+
+	for i, pubKey := range r.PubKeys {
+		if !isPEM(pubKey) {
+			return fmt.Errorf("pubKeys[%d] is not a PEM encoded key", i)
+		}
+	}
+	for i, keyless := range r.Keyless {
+		if keyless.Issuer == "" || keyless.Subject == "" {
+			return fmt.Errorf("keyless[%d] must have issuer and subject", i)
+		}
+	}
+	for i, keylessPrefix := range r.KeylessPrefix {
+		if keylessPrefix.Issuer == "" || keylessPrefix.URLPrefix == "" {
+			return fmt.Errorf("keylessPrefix[%d] must have issuer and urlPrefix", i)
+		}
+	}
+	if r.GitHubAction != nil && r.GitHubAction.Owner == "" {
+		return errors.New("githubAction must have owner")
+	}
+	if r.Certificate != nil {
+		if !isPEM(r.Certificate.Certificate) {
+			return errors.New("certificate.certificate is not a PEM encoded certificate")
+		}
+		for i, certificate := range r.Certificate.CertificateChain {
+			if !isPEM(certificate) {
+				return fmt.Errorf("certificate.certificateChain[%d] is not a PEM encoded certificate", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isPEM(data string) bool {
+	block, _ := pem.Decode([]byte(data))
+
+	return block != nil
+}
+
+// verifier returns the verifier configured by the trust root for the images of the builder.
+func (r *SigstoreTrustRoot) verifier(builder sigstoreVerifierBuilder) sigstoreVerifier {
+	switch {
+	case len(r.PubKeys) > 0:
+		return sigstorePubKeysVerifier{
+			receiverOnlyObjectVal: receiverOnlyVal(sigstorePubKeysVerifierType),
+			images:                builder.images,
+			annotations:           builder.annotations,
+			pubKeys:               r.PubKeys,
+		}
+	case len(r.Keyless) > 0:
+		keyless := make([]oci.KeylessInfo, 0, len(r.Keyless))
+		for _, info := range r.Keyless {
+			keyless = append(keyless, oci.KeylessInfo{Issuer: info.Issuer, Subject: info.Subject})
+		}
+
+		return sigstoreKeylessVerifier{
+			receiverOnlyObjectVal: receiverOnlyVal(sigstoreKeylessVerifierType),
+			images:                builder.images,
+			annotations:           builder.annotations,
+			keyless:               keyless,
+		}
+	case len(r.KeylessPrefix) > 0:
+		keylessPrefix := make([]verify.KeylessPrefixInfo, 0, len(r.KeylessPrefix))
+		for _, info := range r.KeylessPrefix {
+			keylessPrefix = append(keylessPrefix, verify.KeylessPrefixInfo{Issuer: info.Issuer, UrlPrefix: info.URLPrefix})
+		}
+
+		return sigstoreKeylessPrefixVerifier{
+			receiverOnlyObjectVal: receiverOnlyVal(sigstoreKeylessPrefixVerifierType),
+			images:                builder.images,
+			annotations:           builder.annotations,
+			keylessPrefix:         keylessPrefix,
+		}
+	case r.GitHubAction != nil:
+		return sigstoreGitHubActionVerifier{
+			receiverOnlyObjectVal: receiverOnlyVal(sigstoreGitHubActionVerifierType),
+			images:                builder.images,
+			annotations:           builder.annotations,
+			owner:                 r.GitHubAction.Owner,
+			repo:                  r.GitHubAction.Repo,
+		}
+	default:
+		certificateChain := make([][]rune, 0, len(r.Certificate.CertificateChain))
+		for _, certificate := range r.Certificate.CertificateChain {
+			certificateChain = append(certificateChain, []rune(certificate))
+		}
+
+		return sigstoreCertificateVerifier{
+			receiverOnlyObjectVal: receiverOnlyVal(sigstoreCertificateVerifierType),
+			images:                builder.images,
+			annotations:           builder.annotations,
+			certificate:           []rune(r.Certificate.Certificate),
+			certificateChain:      certificateChain,
+			requireRekorBundle:    r.Certificate.RequireRekorBundle,
+		}
+	}
+}
+
+func (l *sigstoreLib) verifierBuilderTrustRoot(arg1, arg2 ref.Val) ref.Val {
+	builder, ok := arg1.(sigstoreVerifierBuilder)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg1)
+	}
+
+	name, ok := arg2.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg2)
+	}
+
+	trustRoot, ok := l.trustRoots[name]
+	if !ok {
+		return types.NewErr("unknown trust root '%s'", name)
+	}
+
+	return sigstoreTrustRootVerifier{
+		receiverOnlyObjectVal: receiverOnlyVal(sigstoreTrustRootVerifierType),
+		sigstoreVerifier:      trustRoot.verifier(builder),
+	}
+}
+
+var sigstoreTrustRootVerifierType = cel.ObjectType("kw.sigstore.TrustRootVerifier")
+
+// sigstoreTrustRootVerifier verifies the signature of an image using the
+// verifier configured by a trust root.
+type sigstoreTrustRootVerifier struct {
+	receiverOnlyObjectVal
+	sigstoreVerifier
+}
+
+// sigstoreTrustRootValidator reports the unknown trust roots given as string
+// literals to the `trustRoot` function, so that they are found when the
+// settings are validated.
+type sigstoreTrustRootValidator struct {
+	trustRoots map[string]SigstoreTrustRoot
+}
+
+func (sigstoreTrustRootValidator) Name() string {
+	return "kw.validator.sigstoreTrustRoot"
+}
+
+func (v sigstoreTrustRootValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, a *ast.AST, issues *cel.Issues) {
+	for _, call := range ast.MatchDescendants(ast.NavigateAST(a), ast.FunctionMatcher("trustRoot")) {
+		args := call.AsCall().Args()
+		if len(args) != 1 || args[0].Kind() != ast.LiteralKind {
+			continue
+		}
+
+		name, ok := args[0].AsLiteral().(types.String)
+		if !ok {
+			continue
+		}
+
+		if _, found := v.trustRoots[string(name)]; !found {
+			issues.ReportErrorAtID(args[0].ID(), "unknown trust root '%s'", name)
+		}
+	}
+}
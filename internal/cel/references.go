@@ -0,0 +1,95 @@
+package cel
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+)
+
+const (
+	variablesIdent         = "variables"
+	hostCapabilitiesPrefix = "kw."
+)
+
+// ReferencedVariables returns the names of the variables referenced by the
+// expression through the `variables` object, in order of appearance and
+// without duplicates.
+// The AST does not need to be type-checked, so that references to variables
+// not yet declared in the environment can be found. When the AST is checked,
+// the checker has already resolved the selections into `variables.<name>`
+// identifiers, which are handled as well.
+func ReferencedVariables(ast *cel.Ast) []string {
+	var names []string
+
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		name, ok := referencedVariable(expr)
+		if !ok {
+			return
+		}
+
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}))
+
+	return names
+}
+
+// referencedVariable returns the name of the variable referenced by the
+// expression, if the expression is a reference to a variable.
+func referencedVariable(expr celast.Expr) (string, bool) {
+	if expr.Kind() == celast.IdentKind {
+		return strings.CutPrefix(expr.AsIdent(), variablesIdent+".")
+	}
+
+	if expr.Kind() == celast.SelectKind {
+		sel := expr.AsSelect()
+		if sel.Operand().Kind() == celast.IdentKind && sel.Operand().AsIdent() == variablesIdent {
+			return sel.FieldName(), true
+		}
+	}
+
+	return "", false
+}
+
+// UsesHostCapabilities returns true when the checked AST calls any function
+// of the Kubewarden host capabilities libraries.
+func UsesHostCapabilities(ast *cel.Ast) bool {
+	found := false
+
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() == celast.CallKind && strings.HasPrefix(expr.AsCall().FunctionName(), hostCapabilitiesPrefix) {
+			found = true
+		}
+	}))
+
+	return found
+}
+
+// CallsWithinComprehension returns true when the AST calls the given function
+// within the loop of a comprehension, where the call is evaluated once for each
+// element of the range. The range and the result of the comprehension are
+// evaluated once, and they are not considered.
+func CallsWithinComprehension(ast *cel.Ast, function string) bool {
+	found := false
+
+	visitor := celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() == celast.CallKind && expr.AsCall().FunctionName() == function {
+			found = true
+		}
+	})
+
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() != celast.ComprehensionKind {
+			return
+		}
+
+		comprehension := expr.AsComprehension()
+		celast.PreOrderVisit(comprehension.LoopCondition(), visitor)
+		celast.PreOrderVisit(comprehension.LoopStep(), visitor)
+	}))
+
+	return found
+}
@@ -1,8 +1,14 @@
 package cel
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
+	"time"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 )
 
 var (
@@ -27,3 +33,41 @@ func IsCELIdentifier(name string) bool {
 	// 	 | "var" | "void" | "while"
 	return celIdentRegex.MatchString(name) && !slices.Contains(celReserved, name)
 }
+
+// NativeValue converts a CEL value into a Go value that can be marshaled to JSON.
+// Values that have no JSON representation, like the objects returned by the
+// host capabilities libraries, are converted to nil.
+func NativeValue(val ref.Val) any {
+	switch v := val.(type) {
+	case types.Null:
+		return nil
+	case types.Timestamp:
+		return v.Time.Format(time.RFC3339Nano)
+	case types.Duration:
+		return v.Duration.String()
+	case *types.Optional:
+		if !v.HasValue() {
+			return nil
+		}
+		return NativeValue(v.GetValue())
+	case traits.Mapper:
+		result := map[string]any{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			result[fmt.Sprint(NativeValue(key))] = NativeValue(v.Get(key))
+		}
+		return result
+	case traits.Lister:
+		result := []any{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			result = append(result, NativeValue(it.Next()))
+		}
+		return result
+	}
+
+	if _, ok := val.Value().(ref.Val); ok {
+		return nil
+	}
+
+	return val.Value()
+}
@@ -0,0 +1,300 @@
+// Package hostreplay implements a policy host client answering the host
+// capabilities calls with the exchanges recorded by kwctl in a session file.
+// This allows to run the policy with real host responses outside of the
+// policy host, e.g. under `go test`.
+//
+// A session file is recorded by `kwctl run --record-host-capabilities-interactions`
+// and has the following format:
+//
+//	# one entry per host capability call
+//	- type: Exchange
+//	  request: |
+//	    !KubernetesGetResource
+//	    api_version: v1
+//	    kind: Namespace
+//	    name: default
+//	    disable_cache: false
+//	  response:
+//	    type: Success
+//	    payload: '{"apiVersion":"v1","kind":"Namespace", ...}'
+package hostreplay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	binding             = "kubewarden"
+	responseTypeOK      = "Success"
+	responseTypeError   = "Error"
+	exchangeType        = "Exchange"
+	sigstoreTypeField   = "type"
+	imageField          = "image"
+	hostField           = "host"
+	sigstoreOperation   = "v2/verify"
+	kubernetesNamespace = "kubernetes"
+)
+
+// capability identifies the host capability a recorded request is sent to,
+// and how the recorded request is translated into the payload sent by the policy.
+type capability struct {
+	namespace string
+	operation string
+	// payload returns the payload sent by the policy from the fields of the recorded request.
+	payload func(tag string, fields map[string]any) any
+}
+
+func fieldsPayload(_ string, fields map[string]any) any {
+	return fields
+}
+
+func imagePayload(_ string, fields map[string]any) any {
+	return fields[imageField]
+}
+
+func hostPayload(_ string, fields map[string]any) any {
+	return fields[hostField]
+}
+
+// sigstorePayload adds the type of verification, which is part of the payload
+// sent by the policy but it is the tag of the recorded request.
+func sigstorePayload(tag string, fields map[string]any) any {
+	payload := make(map[string]any, len(fields)+1)
+	for key, value := range fields {
+		payload[key] = value
+	}
+	payload[sigstoreTypeField] = tag
+
+	return payload
+}
+
+// capabilities maps the tags of the recorded requests to the host capabilities.
+//
+//nolint:gochecknoglobals // the map cannot be const
+var capabilities = map[string]capability{
+	"KubernetesListResourceAll":       {kubernetesNamespace, "list_resources_all", fieldsPayload},
+	"KubernetesListResourceNamespace": {kubernetesNamespace, "list_resources_by_namespace", fieldsPayload},
+	"KubernetesGetResource":           {kubernetesNamespace, "get_resource", fieldsPayload},
+	"KubernetesCanI":                  {kubernetesNamespace, "can_i", fieldsPayload},
+	"OciManifestDigest":               {"oci", "v1/manifest_digest", imagePayload},
+	"OciManifest":                     {"oci", "v1/oci_manifest", imagePayload},
+	"OciManifestAndConfig":            {"oci", "v1/oci_manifest_config", imagePayload},
+	"SigstorePubKeyVerify":            {"oci", sigstoreOperation, sigstorePayload},
+	"SigstoreKeylessVerify":           {"oci", sigstoreOperation, sigstorePayload},
+	"SigstoreKeylessPrefixVerify":     {"oci", sigstoreOperation, sigstorePayload},
+	"SigstoreGithubActionsVerify":     {"oci", sigstoreOperation, sigstorePayload},
+	"SigstoreCertificateVerify":       {"oci", sigstoreOperation, sigstorePayload},
+	"CryptoVerifyCert":                {"crypto", "v1/is_certificate_trusted", fieldsPayload},
+	"DNSLookupHost":                   {"net", "v1/dns_lookup_host", hostPayload},
+}
+
+// exchange is a recorded host capability call.
+type exchange struct {
+	namespace string
+	operation string
+	// request is the payload expected from the policy, normalized.
+	request any
+	// response is the payload returned to the policy.
+	response []byte
+	// err is returned to the policy instead of the response, when set.
+	err  error
+	used bool
+}
+
+// Session replays the exchanges recorded in a kwctl session file.
+// It implements the capabilities.WapcClient interface.
+// Every call must match a recorded exchange, calls that do not match any
+// exchange fail and are reported by Unmatched.
+type Session struct {
+	mu        sync.Mutex
+	exchanges []*exchange
+	unmatched []string
+}
+
+// recordedExchange is the format of an exchange inside of the session file.
+type recordedExchange struct {
+	Type     string `yaml:"type"`
+	Request  string `yaml:"request"`
+	Response struct {
+		Type    string `yaml:"type"`
+		Payload string `yaml:"payload"`
+		Message string `yaml:"message"`
+	} `yaml:"response"`
+}
+
+// Load reads the exchanges of the given kwctl session files.
+func Load(paths ...string) (*Session, error) {
+	session := &Session{}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read session file: %w", err)
+		}
+
+		exchanges, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse session file %s: %w", path, err)
+		}
+
+		session.exchanges = append(session.exchanges, exchanges...)
+	}
+
+	return session, nil
+}
+
+func parse(data []byte) ([]*exchange, error) {
+	var recorded []recordedExchange
+	if err := yaml.Unmarshal(data, &recorded); err != nil {
+		return nil, err
+	}
+
+	exchanges := make([]*exchange, 0, len(recorded))
+	for index, r := range recorded {
+		if r.Type != exchangeType {
+			return nil, fmt.Errorf("exchange %d: unsupported type '%s'", index, r.Type)
+		}
+
+		var request yaml.Node
+		if err := yaml.Unmarshal([]byte(r.Request), &request); err != nil {
+			return nil, fmt.Errorf("exchange %d: cannot parse request: %w", index, err)
+		}
+		if request.Kind != yaml.DocumentNode || len(request.Content) != 1 {
+			return nil, fmt.Errorf("exchange %d: request is empty", index)
+		}
+
+		tag := strings.TrimPrefix(request.Content[0].Tag, "!")
+		capability, found := capabilities[tag]
+		if !found {
+			return nil, fmt.Errorf("exchange %d: unsupported request '%s'", index, tag)
+		}
+
+		fields := map[string]any{}
+		// the tag is removed, so that the node is decoded as a plain map
+		request.Content[0].Tag = ""
+		if err := request.Content[0].Decode(&fields); err != nil {
+			return nil, fmt.Errorf("exchange %d: cannot decode request: %w", index, err)
+		}
+
+		payload, err := normalize(capability.payload(tag, fields))
+		if err != nil {
+			return nil, fmt.Errorf("exchange %d: %w", index, err)
+		}
+
+		e := &exchange{
+			namespace: capability.namespace,
+			operation: capability.operation,
+			request:   payload,
+		}
+
+		switch r.Response.Type {
+		case responseTypeOK:
+			e.response = []byte(r.Response.Payload)
+		case responseTypeError:
+			e.err = errors.New(r.Response.Message)
+		default:
+			return nil, fmt.Errorf("exchange %d: unsupported response type '%s'", index, r.Response.Type)
+		}
+
+		exchanges = append(exchanges, e)
+	}
+
+	return exchanges, nil
+}
+
+// HostCall answers the call with the response of a recorded exchange with the
+// same request. Exchanges are used in the recorded order, and an exchange
+// already used is replayed when no other exchange matches, since the number
+// of calls done by the policy depends on the caching of the host responses.
+func (s *Session) HostCall(callBinding, namespace, operation string, payload []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var request any
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal host call request: %w", err)
+	}
+
+	request, err := normalize(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var replayed *exchange
+	for _, e := range s.exchanges {
+		if callBinding != binding || e.namespace != namespace || e.operation != operation || !reflect.DeepEqual(e.request, request) {
+			continue
+		}
+
+		if !e.used {
+			replayed = e
+			break
+		}
+		if replayed == nil {
+			replayed = e
+		}
+	}
+
+	if replayed == nil {
+		call := fmt.Sprintf("%s/%s/%s %s", callBinding, namespace, operation, payload)
+		s.unmatched = append(s.unmatched, call)
+
+		return nil, fmt.Errorf("no recorded exchange for host call: %s", call)
+	}
+
+	replayed.used = true
+
+	return replayed.response, replayed.err
+}
+
+// Unmatched returns the host calls that did not match any recorded exchange.
+func (s *Session) Unmatched() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.unmatched...)
+}
+
+// normalize converts the value to its JSON representation, removing the null
+// fields of the objects. This way the fields omitted by the policy match the
+// null fields of the recorded requests.
+func normalize(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal request: %w", err)
+	}
+
+	var normalized any
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal request: %w", err)
+	}
+
+	return removeNulls(normalized), nil
+}
+
+func removeNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = removeNulls(field)
+		}
+	case []any:
+		for index, item := range v {
+			v[index] = removeNulls(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,187 @@
+package hostreplay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const session = `
+- type: Exchange
+  request: |
+    !KubernetesGetResource
+    api_version: v1
+    kind: Namespace
+    name: default
+    namespace: null
+    disable_cache: false
+  response:
+    type: Success
+    payload: '{"kind":"Namespace"}'
+- type: Exchange
+  request: |
+    !OciManifestDigest
+    image: ghcr.io/kubewarden/policy-server:latest
+  response:
+    type: Error
+    message: manifest unknown
+- type: Exchange
+  request: |
+    !SigstorePubKeyVerify
+    image: ghcr.io/kubewarden/policy-server:latest
+    pub_keys:
+    - key
+    annotations: null
+  response:
+    type: Success
+    payload: '{"is_trusted":true,"digest":"sha256:abc"}'
+- type: Exchange
+  request: |
+    !DNSLookupHost
+    host: kubewarden.io
+  response:
+    type: Success
+    payload: '{"ips":["127.0.0.1"]}'
+`
+
+func loadSession(t *testing.T, content string) *Session {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "session.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	s, err := Load(path)
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestHostCall(t *testing.T) {
+	tests := []struct {
+		name             string
+		namespace        string
+		operation        string
+		payload          string
+		expectedResponse string
+		expectedError    string
+	}{
+		{
+			name:             "kubernetes request with omitted null fields",
+			namespace:        "kubernetes",
+			operation:        "get_resource",
+			payload:          `{"api_version":"v1","kind":"Namespace","name":"default","disable_cache":false}`,
+			expectedResponse: `{"kind":"Namespace"}`,
+		},
+		{
+			name:          "recorded error",
+			namespace:     "oci",
+			operation:     "v1/manifest_digest",
+			payload:       `"ghcr.io/kubewarden/policy-server:latest"`,
+			expectedError: "manifest unknown",
+		},
+		{
+			name:             "sigstore request with verification type",
+			namespace:        "oci",
+			operation:        "v2/verify",
+			payload:          `{"type":"SigstorePubKeyVerify","image":"ghcr.io/kubewarden/policy-server:latest","pub_keys":["key"]}`,
+			expectedResponse: `{"is_trusted":true,"digest":"sha256:abc"}`,
+		},
+		{
+			name:             "dns lookup",
+			namespace:        "net",
+			operation:        "v1/dns_lookup_host",
+			payload:          `"kubewarden.io"`,
+			expectedResponse: `{"ips":["127.0.0.1"]}`,
+		},
+		{
+			name:          "unmatched request",
+			namespace:     "kubernetes",
+			operation:     "get_resource",
+			payload:       `{"api_version":"v1","kind":"Namespace","name":"kube-system","disable_cache":false}`,
+			expectedError: "no recorded exchange for host call",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := loadSession(t, session)
+
+			response, err := s.HostCall("kubewarden", test.namespace, test.operation, []byte(test.payload))
+			if test.expectedError != "" {
+				require.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.JSONEq(t, test.expectedResponse, string(response))
+			assert.Empty(t, s.Unmatched())
+		})
+	}
+}
+
+func TestHostCallReplaysUsedExchanges(t *testing.T) {
+	s := loadSession(t, session)
+	payload := []byte(`"kubewarden.io"`)
+
+	for range 2 {
+		response, err := s.HostCall("kubewarden", "net", "v1/dns_lookup_host", payload)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ips":["127.0.0.1"]}`, string(response))
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	s := loadSession(t, session)
+
+	_, err := s.HostCall("kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`))
+	require.Error(t, err)
+
+	assert.Equal(t, []string{`kubewarden/net/v1/dns_lookup_host "example.com"`}, s.Unmatched())
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{
+			name: "unsupported request",
+			content: `
+- type: Exchange
+  request: |
+    !Unknown
+    foo: bar
+  response:
+    type: Success
+    payload: '{}'
+`,
+			expectedError: "unsupported request 'Unknown'",
+		},
+		{
+			name: "unsupported response",
+			content: `
+- type: Exchange
+  request: |
+    !DNSLookupHost
+    host: kubewarden.io
+  response:
+    type: Timeout
+`,
+			expectedError: "unsupported response type 'Timeout'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+
+			_, err := Load(path)
+			require.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package policytest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/kubewarden/cel-policy/internal/hostreplay"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	defaultBinding      = "kubewarden"
+	kubernetesNamespace = "kubernetes"
+)
+
+// fakeHost answers the host capabilities calls of the policy with the
+// fixtures of a test case.
+// Calls that do not match any fixture are answered by the session of the
+// test case, if any, otherwise they are recorded, so the test case fails.
+type fakeHost struct {
+	testCase  *Case
+	session   *hostreplay.Session
+	unmatched []string
+}
+
+func newFakeHost(testCase *Case) (*fakeHost, error) {
+	host := &fakeHost{testCase: testCase}
+
+	if testCase.SessionPath != "" {
+		session, err := hostreplay.Load(testCase.SessionPath)
+		if err != nil {
+			return nil, err
+		}
+		host.session = session
+	}
+
+	return host, nil
+}
+
+// HostCall implements the capabilities.WapcClient interface.
+func (h *fakeHost) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	var request any
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal host call request: %w", err)
+	}
+
+	for _, call := range h.testCase.HostCalls {
+		callBinding := call.Binding
+		if callBinding == "" {
+			callBinding = defaultBinding
+		}
+
+		if callBinding != binding || call.Namespace != namespace || call.Operation != operation || !jsonMatch(call.Request, request) {
+			continue
+		}
+
+		if call.Error != "" {
+			return nil, errors.New(call.Error)
+		}
+
+		return json.Marshal(call.Response)
+	}
+
+	if binding == defaultBinding && namespace == kubernetesNamespace {
+		response, ok, err := h.kubernetesFixture(operation, request)
+		if err != nil {
+			call := fmt.Sprintf("%s/%s/%s %s: %s", binding, namespace, operation, payload, err)
+			h.unmatched = append(h.unmatched, call)
+
+			return nil, fmt.Errorf("unexpected host call: %s", call)
+		}
+		if ok {
+			return json.Marshal(response)
+		}
+	}
+
+	if h.session != nil {
+		return h.session.HostCall(binding, namespace, operation, payload)
+	}
+
+	call := fmt.Sprintf("%s/%s/%s %s", binding, namespace, operation, payload)
+	h.unmatched = append(h.unmatched, call)
+
+	return nil, fmt.Errorf("unexpected host call: %s", call)
+}
+
+// Unmatched returns the host calls that did not match any fixture nor any
+// recorded exchange, and the calls the fixtures cannot answer.
+func (h *fakeHost) Unmatched() []string {
+	if h.session != nil {
+		return append(slices.Clone(h.unmatched), h.session.Unmatched()...)
+	}
+
+	return h.unmatched
+}
+
+// kubernetesFixture returns the namespace or the params fixture matching
+// the Kubernetes capability request.
+// The lists are filtered by namespace and label selector, like the host does.
+// The field selectors cannot be honored by the fixtures, they are reported as
+// an error: the calls using them must be mocked by a host call fixture.
+func (h *fakeHost) kubernetesFixture(operation string, request any) (any, bool, error) {
+	fields, ok := request.(map[string]any)
+	if !ok {
+		return nil, false, nil
+	}
+	apiVersion, _ := fields["api_version"].(string)
+	kind, _ := fields["kind"].(string)
+	name, _ := fields["name"].(string)
+
+	switch operation {
+	case "get_resource":
+		if h.testCase.Namespace != nil && apiVersion == "v1" && kind == "Namespace" && objectName(h.testCase.Namespace) == name {
+			return h.testCase.Namespace, true, nil
+		}
+
+		for _, params := range h.testCase.Params {
+			if params["apiVersion"] == apiVersion && params["kind"] == kind && objectName(params) == name {
+				return params, true, nil
+			}
+		}
+	case "list_resources_by_namespace", "list_resources_all":
+		if fieldSelector, _ := fields["field_selector"].(string); fieldSelector != "" {
+			return nil, false, fmt.Errorf("the params fixtures do not support the field selector %q", fieldSelector)
+		}
+
+		labelSelector, _ := fields["label_selector"].(string)
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid label selector: %w", err)
+		}
+
+		namespace, _ := fields["namespace"].(string)
+		found := false
+		items := []any{}
+		for _, params := range h.testCase.Params {
+			if params["apiVersion"] != apiVersion || params["kind"] != kind {
+				continue
+			}
+			found = true
+			if operation == "list_resources_by_namespace" && objectNamespace(params) != namespace {
+				continue
+			}
+			if !selector.Matches(labels.Set(objectLabels(params))) {
+				continue
+			}
+			items = append(items, params)
+		}
+
+		// the list is empty when the fixtures of the kind do not match the request
+		if found {
+			return map[string]any{"items": items}, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func objectName(object map[string]any) string {
+	return objectMetadata(object, "name")
+}
+
+func objectNamespace(object map[string]any) string {
+	return objectMetadata(object, "namespace")
+}
+
+func objectMetadata(object map[string]any, field string) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	value, _ := metadata[field].(string)
+
+	return value
+}
+
+func objectLabels(object map[string]any) map[string]string {
+	metadata, _ := object["metadata"].(map[string]any)
+	fields, _ := metadata["labels"].(map[string]any)
+
+	result := make(map[string]string, len(fields))
+	for key, value := range fields {
+		result[key], _ = value.(string)
+	}
+
+	return result
+}
+
+// jsonMatch compares the expected value of a fixture with the actual value
+// decoded from JSON. Objects match when all the fields of the expected object
+// match, the actual object can have more fields.
+func jsonMatch(expected, actual any) bool {
+	expectedFields, ok := expected.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(expected, actual)
+	}
+
+	actualFields, ok := actual.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	for key, value := range expectedFields {
+		if !jsonMatch(value, actualFields[key]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,207 @@
+package policytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubewarden/cel-policy/internal/settings"
+	"github.com/kubewarden/cel-policy/internal/validate"
+)
+
+// Result is the outcome of a test case.
+type Result struct {
+	Suite string
+	Case  string
+	// Failures describes the differences between the expected and the actual outcome.
+	Failures []string
+}
+
+// Passed returns true when the outcome of the test case is the expected one.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// validationResponse is the response of the policy.
+type validationResponse struct {
+	Accepted bool    `json:"accepted"`
+	Message  *string `json:"message,omitempty"`
+	Code     *uint16 `json:"code,omitempty"`
+}
+
+// Run loads the test suites found at the given paths and runs their test cases.
+// A path can be a suite file or a directory, in which case all the YAML and
+// JSON files inside of it are loaded as suites.
+func Run(paths []string) ([]Result, error) {
+	files, err := suiteFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, file := range files {
+		suite, err := LoadSuite(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for index := range suite.Cases {
+			results = append(results, runCase(file, &suite.Cases[index]))
+		}
+	}
+
+	return results, nil
+}
+
+func suiteFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	return files, nil
+}
+
+func runCase(suite string, testCase *Case) Result {
+	result := Result{Suite: suite, Case: testCase.Name}
+
+	host, err := newFakeHost(testCase)
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("cannot load session: %s", err))
+		return result
+	}
+	previous := validate.SetHostClient(host)
+	defer validate.SetHostClient(previous)
+
+	payload, err := json.Marshal(map[string]json.RawMessage{
+		"request":  testCase.Request,
+		"settings": testCase.Settings,
+	})
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("cannot build validation request: %s", err))
+		return result
+	}
+
+	settingsPayload, err := settings.ValidateSettings(testCase.Settings)
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("settings validation failed: %s", err))
+		return result
+	}
+
+	settingsResponse := settings.SettingsValidationResponse{}
+	if err = json.Unmarshal(settingsPayload, &settingsResponse); err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("cannot unmarshal settings validation response: %s", err))
+		return result
+	}
+	if !settingsResponse.Valid {
+		result.Failures = append(result.Failures, fmt.Sprintf("invalid settings: %s", valueOrNil(settingsResponse.Message)))
+		return result
+	}
+
+	responsePayload, err := validate.Validate(payload)
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("validation failed: %s", err))
+		return result
+	}
+
+	response := validationResponse{}
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("cannot unmarshal validation response: %s", err))
+		return result
+	}
+
+	for _, call := range host.Unmatched() {
+		result.Failures = append(result.Failures, fmt.Sprintf("unexpected host call: %s", call))
+	}
+
+	result.Failures = append(result.Failures, compare(testCase.Expect, response, settingsResponse.Warnings)...)
+
+	return result
+}
+
+// compare returns the differences between the expected outcome and the
+// response, and the warnings of the settings validation.
+func compare(expect Expectation, response validationResponse, settingsWarnings []string) []string {
+	var failures []string
+
+	if expect.Allowed != response.Accepted {
+		failures = append(failures, diff("allowed", expect.Allowed, response.Accepted))
+	}
+
+	if expect.Code != nil && (response.Code == nil || *expect.Code != *response.Code) {
+		failures = append(failures, diff("code", *expect.Code, valueOrNil(response.Code)))
+	}
+
+	if expect.Message != nil && (response.Message == nil || *expect.Message != *response.Message) {
+		failures = append(failures, diff("message", *expect.Message, valueOrNil(response.Message)))
+	}
+
+	if expect.SettingsWarnings != nil && strings.Join(expect.SettingsWarnings, "\n") != strings.Join(settingsWarnings, "\n") {
+		failures = append(failures, diff("settingsWarnings", expect.SettingsWarnings, settingsWarnings))
+	}
+
+	return failures
+}
+
+func diff(field string, expected, actual any) string {
+	return fmt.Sprintf("%s:\n  expected: %s\n  actual:   %s", field, formatValue(expected), formatValue(actual))
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+func valueOrNil[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+
+	return *value
+}
+
+// Report returns a human readable report of the results, and whether all the
+// test cases passed.
+func Report(results []Result) (string, bool) {
+	var report strings.Builder
+	failed := 0
+
+	for _, result := range results {
+		if result.Passed() {
+			fmt.Fprintf(&report, "PASS %s: %s\n", result.Suite, result.Case)
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(&report, "FAIL %s: %s\n", result.Suite, result.Case)
+		for _, failure := range result.Failures {
+			fmt.Fprintf(&report, "    %s\n", strings.ReplaceAll(failure, "\n", "\n    "))
+		}
+	}
+
+	fmt.Fprintf(&report, "\n%d test cases, %d passed, %d failed\n", len(results), len(results)-failed, failed)
+
+	return report.String(), failed == 0
+}
@@ -0,0 +1,203 @@
+package policytest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubewarden/cel-policy/internal/validate"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTestDataSuites(t *testing.T) {
+	results, err := Run([]string{"../../test_data/tests"})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+
+	for _, result := range results {
+		assert.True(t, result.Passed(), "%s: %s: %v", result.Suite, result.Case, result.Failures)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	suite := `
+settings:
+  validations:
+    - expression: "object.metadata.name != 'forbidden'"
+      message: "forbidden name"
+      reason: Forbidden
+cases:
+  - name: wrong expectation
+    request:
+      object:
+        metadata:
+          name: forbidden
+          namespace: default
+    expect:
+      allowed: false
+      code: 400
+      message: "invalid name"
+  - name: unexpected host call
+    settings:
+      validations:
+        - expression: "kw.net.lookupHost('example.com').size() > 0"
+    request:
+      object:
+        metadata:
+          name: nginx
+    expect:
+      allowed: false
+  - name: mocked host call
+    settings:
+      validations:
+        - expression: "kw.net.lookupHost('example.com').size() > 0"
+    request:
+      object:
+        metadata:
+          name: nginx
+    hostCalls:
+      - namespace: net
+        operation: v1/dns_lookup_host
+        request: example.com
+        response:
+          ips: ["127.0.0.1"]
+    expect:
+      allowed: true
+  - name: settings warnings
+    settings:
+      variables:
+        - name: unused
+          expression: "object.metadata.name"
+      validations:
+        - expression: "object.metadata.name != 'forbidden'"
+    request:
+      object:
+        metadata:
+          name: nginx
+    expect:
+      allowed: true
+      settingsWarnings:
+        - "variables[0].name: variable 'unused' is not used by any validation"
+        - "other warning"
+`
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(suite), 0o600))
+
+	results, err := Run([]string{path})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, []string{
+		"code:\n  expected: 400\n  actual:   403",
+		"message:\n  expected: \"invalid name\"\n  actual:   \"forbidden name\"",
+	}, results[0].Failures)
+
+	assert.False(t, results[1].Passed())
+	assert.Contains(t, results[1].Failures[0], "unexpected host call: kubewarden/net/v1/dns_lookup_host")
+
+	assert.True(t, results[2].Passed(), "%v", results[2].Failures)
+
+	assert.Equal(t, []string{
+		"settingsWarnings:\n  expected: [\"variables[0].name: variable 'unused' is not used by any validation\",\"other warning\"]\n" +
+			"  actual:   [\"variables[0].name: variable 'unused' is not used by any validation\"]",
+	}, results[3].Failures)
+
+	report, passed := Report(results)
+	assert.False(t, passed)
+	assert.Contains(t, report, "4 test cases, 1 passed, 3 failed")
+}
+
+func TestRunRestoresHostClient(t *testing.T) {
+	client := &mocks.MockWapcClient{}
+	previous := validate.SetHostClient(client)
+	t.Cleanup(func() { validate.SetHostClient(previous) })
+
+	_, err := Run([]string{"../../test_data/tests"})
+	require.NoError(t, err)
+
+	assert.Same(t, client, validate.SetHostClient(client))
+}
+
+func TestRunListFixtures(t *testing.T) {
+	suite := `
+settings:
+  validations:
+    - expression: "true"
+cases:
+  - name: namespace and label selector
+    settings:
+      validations:
+        - expression: >
+            kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('default').labelSelector('app=my-app').list().items.map(c, c.metadata.name) == ['selected']
+    request: &request
+      object:
+        metadata:
+          name: nginx
+    params: &params
+      - apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: selected
+          namespace: default
+          labels:
+            app: my-app
+      - apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: other-labels
+          namespace: default
+          labels:
+            app: other-app
+      - apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: other-namespace
+          namespace: other
+          labels:
+            app: my-app
+    expect:
+      allowed: true
+  - name: all namespaces
+    settings:
+      validations:
+        - expression: >
+            kw.k8s.apiVersion('v1').kind('ConfigMap').labelSelector('app=my-app').list().items.map(c, c.metadata.name) == ['selected', 'other-namespace']
+    request: *request
+    params: *params
+    expect:
+      allowed: true
+  - name: no match
+    settings:
+      validations:
+        - expression: >
+            kw.k8s.apiVersion('v1').kind('ConfigMap').namespace('kube-system').list().items.size() == 0
+    request: *request
+    params: *params
+    expect:
+      allowed: true
+  - name: field selector
+    settings:
+      validations:
+        - expression: >
+            kw.k8s.apiVersion('v1').kind('ConfigMap').fieldSelector('metadata.name=selected').list().items.size() == 1
+    request: *request
+    params: *params
+    expect:
+      allowed: true
+`
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(suite), 0o600))
+
+	results, err := Run([]string{path})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	for _, result := range results[:3] {
+		assert.True(t, result.Passed(), "%s: %v", result.Case, result.Failures)
+	}
+
+	assert.False(t, results[3].Passed())
+	assert.Contains(t, results[3].Failures[0], `the params fixtures do not support the field selector "metadata.name=selected"`)
+}
@@ -0,0 +1,168 @@
+// Package policytest implements a runner for declarative test suites of CEL policies.
+// The test cases are evaluated in-process through the policy validation, with
+// the host capabilities served by the fixtures of each case.
+package policytest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of test cases sharing the same policy settings.
+type Suite struct {
+	// path is the file the suite was loaded from. Relative paths inside of the
+	// suite are resolved from its directory.
+	path string
+	// Settings are the policy settings.
+	Settings json.RawMessage `json:"settings,omitempty"`
+	// SettingsPath is the path of a JSON or YAML file with the policy settings.
+	// It is ignored when Settings is set.
+	SettingsPath string `json:"settingsPath,omitempty"`
+	// SessionPath is the default session file of the cases.
+	SessionPath string `json:"sessionPath,omitempty"`
+	Cases       []Case `json:"cases"`
+}
+
+// Case is a single admission request evaluated by the policy.
+type Case struct {
+	Name string `json:"name"`
+	// Settings overrides the settings of the suite.
+	Settings json.RawMessage `json:"settings,omitempty"`
+	// Request is the admission request to evaluate.
+	Request json.RawMessage `json:"request,omitempty"`
+	// RequestPath is the path of a JSON or YAML file with the admission request.
+	// It is ignored when Request is set.
+	RequestPath string `json:"requestPath,omitempty"`
+	// Namespace is the Namespace object returned to the policy when fetching the
+	// namespace of the request.
+	Namespace map[string]any `json:"namespace,omitempty"`
+	// Params are the objects returned to the policy when fetching the params.
+	// They are matched by their apiVersion and kind, and the lists are filtered
+	// by namespace and label selector. Field selectors are not supported.
+	Params []map[string]any `json:"params,omitempty"`
+	// HostCalls are the responses of the host capabilities calls done by the policy.
+	HostCalls []HostCall `json:"hostCalls,omitempty"`
+	// SessionPath is the path of a kwctl session file. The host capabilities calls
+	// that do not match any fixture are answered by the exchanges recorded in it.
+	SessionPath string      `json:"sessionPath,omitempty"`
+	Expect      Expectation `json:"expect"`
+}
+
+// HostCall is the mocked response of a host capability call.
+type HostCall struct {
+	// Binding defaults to `kubewarden`.
+	Binding string `json:"binding,omitempty"`
+	// Namespace is the capability, e.g. `kubernetes` or `oci`.
+	Namespace string `json:"namespace"`
+	// Operation is the capability operation, e.g. `get_resource`.
+	Operation string `json:"operation"`
+	// Request is the expected request payload. It is compared as a JSON value.
+	Request any `json:"request"`
+	// Response is the payload returned to the policy.
+	Response any `json:"response,omitempty"`
+	// Error is returned to the policy instead of the response, when set.
+	Error string `json:"error,omitempty"`
+}
+
+// Expectation is the expected outcome of a test case.
+// Fields that are not set are not checked, except for Allowed.
+type Expectation struct {
+	Allowed bool    `json:"allowed"`
+	Code    *uint16 `json:"code,omitempty"`
+	Message *string `json:"message,omitempty"`
+	// SettingsWarnings are the warnings found by the settings validation,
+	// e.g. the variables that are not used by any validation.
+	SettingsWarnings []string `json:"settingsWarnings,omitempty"`
+}
+
+// LoadSuite loads a test suite from a YAML or JSON file.
+func LoadSuite(path string) (*Suite, error) {
+	suite := &Suite{path: path}
+	if err := readYAML(path, suite); err != nil {
+		return nil, fmt.Errorf("cannot load test suite %s: %w", path, err)
+	}
+
+	if len(suite.Settings) == 0 && suite.SettingsPath != "" {
+		settings, err := readJSON(suite.resolve(suite.SettingsPath))
+		if err != nil {
+			return nil, fmt.Errorf("cannot load settings of test suite %s: %w", path, err)
+		}
+		suite.Settings = settings
+	}
+
+	for index := range suite.Cases {
+		testCase := &suite.Cases[index]
+		if testCase.Name == "" {
+			testCase.Name = fmt.Sprintf("case %d", index)
+		}
+
+		if len(testCase.Settings) == 0 {
+			testCase.Settings = suite.Settings
+		}
+		if len(testCase.Settings) == 0 {
+			return nil, fmt.Errorf("test suite %s: %s: settings are not specified", path, testCase.Name)
+		}
+
+		if len(testCase.Request) == 0 && testCase.RequestPath != "" {
+			request, err := readJSON(suite.resolve(testCase.RequestPath))
+			if err != nil {
+				return nil, fmt.Errorf("test suite %s: %s: cannot load request: %w", path, testCase.Name, err)
+			}
+			testCase.Request = request
+		}
+		if len(testCase.Request) == 0 {
+			return nil, fmt.Errorf("test suite %s: %s: request is not specified", path, testCase.Name)
+		}
+
+		if testCase.SessionPath == "" {
+			testCase.SessionPath = suite.SessionPath
+		}
+		if testCase.SessionPath != "" {
+			testCase.SessionPath = suite.resolve(testCase.SessionPath)
+		}
+	}
+
+	return suite, nil
+}
+
+// resolve returns the path relative to the directory of the suite.
+func (s *Suite) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(s.path), path)
+}
+
+// readYAML reads a YAML file, JSON included, into a value with JSON field tags.
+func readYAML(path string, value any) error {
+	data, err := readJSON(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+// readJSON reads a YAML file, JSON included, and returns its content as JSON.
+func readJSON(path string) (json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var content any
+	if err = yaml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, errors.New("file is empty")
+	}
+
+	return json.Marshal(content)
+}
@@ -1,6 +1,65 @@
 package settings
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/kubewarden/cel-policy/internal/cel"
+)
+
+// ErrorType is the kind of problem found in a settings field.
+type ErrorType string
+
+const (
+	ErrorTypeRequired     ErrorType = "required"
+	ErrorTypeInvalid      ErrorType = "invalid"
+	ErrorTypeNotSupported ErrorType = "notSupported"
+	ErrorTypeDuplicate    ErrorType = "duplicate"
+)
+
+// FieldError is the machine readable representation of a problem found
+// in a settings field.
+type FieldError struct {
+	// Field is the path of the field, e.g. `validations[3].messageExpression`.
+	Field string    `json:"field"`
+	Type  ErrorType `json:"type"`
+	// Value is the offending value, if any.
+	Value string `json:"value,omitempty"`
+	// Detail describes the problem.
+	Detail string `json:"detail,omitempty"`
+	// Position is the location of the problem inside of the CEL expression
+	// held by the field, if the problem was found while compiling it.
+	Position *cel.Issue `json:"position,omitempty"`
+}
+
+// fieldErrorer is implemented by the errors that can be represented as field errors.
+type fieldErrorer interface {
+	fieldErrors() []FieldError
+}
+
+// fieldErrors returns the field errors of every error held by err.
+// Errors that do not refer to a field are ignored.
+func fieldErrors(err error) []FieldError {
+	var errs []error
+
+	var merr *multierror.Error
+	if errors.As(err, &merr) {
+		errs = merr.WrappedErrors()
+	} else {
+		errs = []error{err}
+	}
+
+	var result []FieldError
+	for _, e := range errs {
+		var fe fieldErrorer
+		if errors.As(e, &fe) {
+			result = append(result, fe.fieldErrors()...)
+		}
+	}
+
+	return result
+}
 
 type requiredValueError struct {
 	path    string
@@ -18,10 +77,16 @@ func (e *requiredValueError) Error() string {
 	return fmt.Sprintf("%s: Required value: %s", e.path, e.message)
 }
 
+func (e *requiredValueError) fieldErrors() []FieldError {
+	return []FieldError{{Field: e.path, Type: ErrorTypeRequired, Detail: e.message}}
+}
+
 type invalidValueError struct {
 	path    string
 	value   string
 	message string
+	// cause is the error that made the value invalid, if any.
+	cause error
 }
 
 func newInvalidValueError(path, value, message string) error {
@@ -32,10 +97,39 @@ func newInvalidValueError(path, value, message string) error {
 	}
 }
 
+// newInvalidExpressionError returns an invalid value error for a CEL expression
+// that keeps the position of the compilation issues found in it.
+func newInvalidExpressionError(path, expression string, cause error) error {
+	return &invalidValueError{
+		path:    path,
+		value:   expression,
+		message: cause.Error(),
+		cause:   cause,
+	}
+}
+
 func (e *invalidValueError) Error() string {
 	return fmt.Sprintf(`%s: Invalid value: "%s": %s:`, e.path, e.value, e.message)
 }
 
+func (e *invalidValueError) Unwrap() error {
+	return e.cause
+}
+
+func (e *invalidValueError) fieldErrors() []FieldError {
+	issues, ok := cel.CompilationIssues(e.cause)
+	if !ok || len(issues) == 0 {
+		return []FieldError{{Field: e.path, Type: ErrorTypeInvalid, Value: e.value, Detail: e.message}}
+	}
+
+	result := make([]FieldError, 0, len(issues))
+	for _, issue := range issues {
+		result = append(result, FieldError{Field: e.path, Type: ErrorTypeInvalid, Value: e.value, Detail: issue.Message, Position: &issue})
+	}
+
+	return result
+}
+
 type notSupportedValueError struct {
 	path  string
 	value string
@@ -51,3 +145,27 @@ func newNotSupportedValueError(path, value string) error {
 func (e *notSupportedValueError) Error() string {
 	return fmt.Sprintf(`%s: Unsupported value: "%s"`, e.path, e.value)
 }
+
+func (e *notSupportedValueError) fieldErrors() []FieldError {
+	return []FieldError{{Field: e.path, Type: ErrorTypeNotSupported, Value: e.value}}
+}
+
+type duplicateValueError struct {
+	path  string
+	value string
+}
+
+func newDuplicateValueError(path, value string) error {
+	return &duplicateValueError{
+		path:  path,
+		value: value,
+	}
+}
+
+func (e *duplicateValueError) Error() string {
+	return fmt.Sprintf(`%s: Duplicate value: "%s"`, e.path, e.value)
+}
+
+func (e *duplicateValueError) fieldErrors() []FieldError {
+	return []FieldError{{Field: e.path, Type: ErrorTypeDuplicate, Value: e.value}}
+}
@@ -0,0 +1,133 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/kubewarden/cel-policy/internal/cel"
+)
+
+// warning is a problem found in settings that are valid, but most likely wrong.
+// Warnings do not make the settings invalid, unless the settings are strict.
+type warning struct {
+	path string
+	// name is the name of the validation the warning refers to, if any.
+	name    string
+	value   string
+	message string
+}
+
+func (w warning) String() string {
+	if w.name != "" {
+		return fmt.Sprintf("%s (%s): %s", w.path, w.name, w.message)
+	}
+
+	return fmt.Sprintf("%s: %s", w.path, w.message)
+}
+
+// asError returns the warning as an error, used when the settings are strict.
+func (w warning) asError() error {
+	return newInvalidValueError(w.path, w.value, w.message)
+}
+
+// lint looks for problems in the settings that do not make them invalid.
+// The variables must be already added to the compiler environment, so that
+// the validations can be type-checked.
+func lint(compiler *cel.Compiler, graph *variableGraph, settings Settings) []warning {
+	warnings := lintUnreachableVariables(compiler, graph, settings.Validations)
+
+	for index, variable := range settings.Variables {
+		warnings = append(warnings, lintFreshWithinComprehension(compiler, fmt.Sprintf("variables[%d].expression", index), "", variable.Expression)...)
+	}
+
+	for index, validation := range settings.Validations {
+		warnings = append(warnings, lintValidation(compiler, index, validation)...)
+		warnings = append(warnings, lintFreshWithinComprehension(compiler, fmt.Sprintf("validations[%d].expression", index), validation.Name, validation.Expression)...)
+		warnings = append(warnings, lintFreshWithinComprehension(compiler, fmt.Sprintf("validations[%d].messageExpression", index), validation.Name, validation.MessageExpression)...)
+	}
+
+	return warnings
+}
+
+// lintUnreachableVariables returns a warning for each variable that is not
+// used by any validation, neither directly nor through other variables.
+func lintUnreachableVariables(compiler *cel.Compiler, graph *variableGraph, validations []Validation) []warning {
+	expressions := make([]string, 0, len(validations))
+	for _, validation := range validations {
+		expressions = append(expressions, validation.Expression, validation.MessageExpression)
+	}
+
+	var warnings []warning
+	for _, index := range graph.unreachable(compiler, expressions) {
+		warnings = append(warnings, warning{
+			path:    fmt.Sprintf("variables[%d].name", index),
+			value:   graph.variables[index].Name,
+			message: fmt.Sprintf("variable '%s' is not used by any validation", graph.variables[index].Name),
+		})
+	}
+
+	return warnings
+}
+
+func lintValidation(compiler *cel.Compiler, index int, validation Validation) []warning {
+	var warnings []warning
+
+	if strings.TrimSpace(validation.Message) != "" && strings.TrimSpace(validation.MessageExpression) != "" {
+		warnings = append(warnings, warning{
+			path:    fmt.Sprintf("validations[%d].message", index),
+			name:    validation.Name,
+			value:   validation.Message,
+			message: "message is ignored because messageExpression is set",
+		})
+	}
+
+	// invalid expressions are already reported by the settings validation
+	ast, err := compiler.CompileCELExpression(validation.Expression)
+	if err != nil {
+		return warnings
+	}
+
+	if val, ok := compiler.ConstantValue(ast); ok {
+		switch val {
+		case types.True:
+			warnings = append(warnings, warning{
+				path:    fmt.Sprintf("validations[%d].expression", index),
+				name:    validation.Name,
+				value:   validation.Expression,
+				message: "expression is always true, the validation never rejects a request",
+			})
+		case types.False:
+			warnings = append(warnings, warning{
+				path:    fmt.Sprintf("validations[%d].expression", index),
+				name:    validation.Name,
+				value:   validation.Expression,
+				message: "expression is always false, the validation rejects every request",
+			})
+		}
+	}
+
+	return warnings
+}
+
+// lintFreshWithinComprehension returns a warning when the expression reads
+// fresh Kubernetes resources within a comprehension: the caches are bypassed
+// for every element, and each iteration calls the Kubernetes API server.
+func lintFreshWithinComprehension(compiler *cel.Compiler, path, name, expression string) []warning {
+	if strings.TrimSpace(expression) == "" {
+		return nil
+	}
+
+	// invalid expressions are already reported by the settings validation
+	ast, err := compiler.CompileCELExpression(expression)
+	if err != nil || !cel.CallsWithinComprehension(ast, "fresh") {
+		return nil
+	}
+
+	return []warning{{
+		path:    path,
+		name:    name,
+		value:   expression,
+		message: "fresh() is used within a comprehension, the Kubernetes API server is called for each element",
+	}}
+}
@@ -0,0 +1,168 @@
+package settings
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name             string
+		settings         Settings
+		expectedWarnings []string
+	}{
+		{
+			name: "no warnings",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "object.spec.replicas < 5",
+						Message:    "too many replicas",
+					},
+				},
+			},
+			expectedWarnings: nil,
+		},
+		{
+			name: "constant true validation",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "object.spec.replicas < 5 || 1 < 2",
+					},
+				},
+			},
+			expectedWarnings: []string{"validations[0].expression: expression is always true, the validation never rejects a request"},
+		},
+		{
+			name: "constant false validation",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "'foo'.size() > 5 && object.spec.replicas < 5",
+					},
+				},
+			},
+			expectedWarnings: []string{"validations[0].expression: expression is always false, the validation rejects every request"},
+		},
+		{
+			name: "host capabilities are not folded",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "kw.net.lookupHost('example.com').size() > 0",
+					},
+				},
+			},
+			expectedWarnings: nil,
+		},
+		{
+			name: "fresh read within comprehension",
+			settings: Settings{
+				Variables: []Variable{
+					{
+						Name:       "bindings",
+						Expression: "object.subjects.map(s, kw.k8s.apiVersion('rbac.authorization.k8s.io/v1').kind('RoleBinding').namespace('default').fresh().has(s.name))",
+					},
+				},
+				Validations: []Validation{
+					{
+						Name:       "subjects",
+						Expression: "object.subjects.all(s, kw.k8s.apiVersion('v1').kind('ServiceAccount').namespace('default').fresh().has(s.name)) && variables.bindings.all(b, b)",
+					},
+				},
+			},
+			expectedWarnings: []string{
+				"variables[0].expression: fresh() is used within a comprehension, the Kubernetes API server is called for each element",
+				"validations[0].expression (subjects): fresh() is used within a comprehension, the Kubernetes API server is called for each element",
+			},
+		},
+		{
+			name: "fresh read outside of comprehension",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "kw.k8s.apiVersion('v1').kind('Pod').namespace('default').fresh().list().items.all(p, p.metadata.name != object.metadata.name)",
+					},
+				},
+			},
+			expectedWarnings: nil,
+		},
+		{
+			name: "named validation",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Name:       "max-replicas",
+						Expression: "1 < 2",
+					},
+				},
+			},
+			expectedWarnings: []string{"validations[0].expression (max-replicas): expression is always true, the validation never rejects a request"},
+		},
+		{
+			name: "message and messageExpression",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression:        "object.spec.replicas < 5",
+						Message:           "too many replicas",
+						MessageExpression: "'too many replicas: ' + string(object.spec.replicas)",
+					},
+				},
+			},
+			expectedWarnings: []string{"validations[0].message: message is ignored because messageExpression is set"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := json.Marshal(test.settings)
+			require.NoError(t, err)
+
+			response, err := ValidateSettings(payload)
+			require.NoError(t, err)
+
+			settingsValidationResponse := SettingsValidationResponse{}
+			err = json.Unmarshal(response, &settingsValidationResponse)
+			require.NoError(t, err)
+
+			assert.True(t, settingsValidationResponse.Valid)
+			assert.Equal(t, test.expectedWarnings, settingsValidationResponse.Warnings)
+		})
+	}
+}
+
+func TestLintStrict(t *testing.T) {
+	settings := Settings{
+		Strict: true,
+		Validations: []Validation{
+			{
+				Expression: "true",
+			},
+		},
+	}
+	payload, err := json.Marshal(settings)
+	require.NoError(t, err)
+
+	response, err := ValidateSettings(payload)
+	require.NoError(t, err)
+
+	settingsValidationResponse := SettingsValidationResponse{}
+	err = json.Unmarshal(response, &settingsValidationResponse)
+	require.NoError(t, err)
+
+	assert.False(t, settingsValidationResponse.Valid)
+	assert.Empty(t, settingsValidationResponse.Warnings)
+	assert.Equal(t, []FieldError{
+		{
+			Field:  "validations[0].expression",
+			Type:   ErrorTypeInvalid,
+			Value:  "true",
+			Detail: "expression is always true, the validation never rejects a request",
+		},
+	}, settingsValidationResponse.Errors)
+}
@@ -0,0 +1,37 @@
+package settings
+
+import (
+	"encoding/json"
+
+	"github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// SettingsValidationResponse extends the Kubewarden settings validation response
+// with the errors and warnings found while validating the settings.
+// Errors are the machine readable version of the response message.
+// Warnings do not make the settings invalid.
+type SettingsValidationResponse struct {
+	protocol.SettingsValidationResponse
+	Errors   []FieldError `json:"errors,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+func acceptSettings(warnings []string) ([]byte, error) {
+	return json.Marshal(SettingsValidationResponse{
+		SettingsValidationResponse: protocol.SettingsValidationResponse{
+			Valid: true,
+		},
+		Warnings: warnings,
+	})
+}
+
+func rejectSettings(message string, errors []FieldError, warnings []string) ([]byte, error) {
+	return json.Marshal(SettingsValidationResponse{
+		SettingsValidationResponse: protocol.SettingsValidationResponse{
+			Valid:   false,
+			Message: &message,
+		},
+		Errors:   errors,
+		Warnings: warnings,
+	})
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/google/cel-go/common/types"
 	"github.com/hashicorp/go-multierror"
 	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/cel/library"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/kubernetes/pkg/apis/admissionregistration"
 )
 
@@ -21,6 +23,24 @@ const (
 	StatusReasonRequestEntityTooLarge = "RequestEntityTooLarge"
 )
 
+// Severities of the PolicyReport results.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+//nolint:gochecknoglobals // []string cannot be const
+var supportedSeverities = []string{
+	SeverityCritical,
+	SeverityHigh,
+	SeverityMedium,
+	SeverityLow,
+	SeverityInfo,
+}
+
 //nolint:gochecknoglobals // []string cannot be const
 var supportedValidationPolicyReason = []string{
 	StatusReasonUnauthorized,
@@ -33,11 +53,23 @@ var supportedValidationPolicyReason = []string{
 type Settings struct {
 	Variables   []Variable   `json:"variables"`
 	Validations []Validation `json:"validations"`
+	// TrustRoots are the named sigstore verifier configurations, used by the
+	// `trustRoot` function of the `kw.sigstore` library.
+	TrustRoots []library.SigstoreTrustRoot `json:"trustRoots,omitempty"`
 	/// FailurePolicy defines how the policy will response to  runtime errors and
 	// invalid or mis-configured policy definitions
 	FailurePolicy admissionregistration.FailurePolicyType `json:"failurePolicy,omitempty"`
 	ParamKind     *admissionregistration.ParamKind        `json:"paramKind,omitempty"`
 	ParamRef      *admissionregistration.ParamRef         `json:"paramRef,omitempty"`
+	// Strict turns the warnings found by linting the settings into errors.
+	Strict bool `json:"strict,omitempty"`
+	// Explain appends to the rejection message the sub-expressions of the
+	// failed validation that evaluated to false, with the values of their operands.
+	Explain bool `json:"explain,omitempty"`
+	// Report evaluates every validation, instead of stopping at the first failure,
+	// and adds the result of each one to the validation response.
+	// This is meant for the background audit reports.
+	Report bool `json:"report,omitempty"`
 }
 
 type Variable struct {
@@ -46,10 +78,17 @@ type Variable struct {
 }
 
 type Validation struct {
+	// Name identifies the validation in the rejection messages, in the warnings
+	// and in the audit annotations. It is optional and must be unique.
+	Name              string `json:"name,omitempty"`
 	Expression        string `json:"expression"`
 	Message           string `json:"message"`
 	MessageExpression string `json:"messageExpression"`
 	Reason            string `json:"reason"`
+	// Severity and Category are reported in the result of the validation,
+	// when the policy runs in report mode.
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
 }
 
 // Write a custom unmarshaller to set default values for FailurePolicy to replicate
@@ -118,12 +157,43 @@ func ValidateSettings(input []byte) ([]byte, error) {
 		result = multierror.Append(result, err)
 	}
 
-	compiler, err := cel.NewCompiler()
+	if err := validateTrustRoots(settings.TrustRoots); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	compiler, err := cel.NewCompiler(settings.TrustRoots...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CEL env: %w", err)
 	}
 
+	graph := newVariableGraph(compiler, settings.Variables)
+	invalidVariables, err := graph.validate()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	variableNames := map[string]bool{}
 	for index, variable := range settings.Variables {
+		if variableNames[variable.Name] {
+			result = multierror.Append(result, newDuplicateValueError(fmt.Sprintf("variables[%d].name", index), variable.Name))
+			continue
+		}
+		variableNames[variable.Name] = true
+
+		if slices.Contains(invalidVariables, index) {
+			// the expression is not compiled because of the reference error, the
+			// variable is declared as dyn so that the expressions referencing it
+			// are not reported as referencing an undefined variable
+			if err := validateVariableName(index, variable); err != nil {
+				result = multierror.Append(result, err)
+				continue
+			}
+			if err := compiler.AddVariable(variable.Name, types.DynType); err != nil {
+				return nil, fmt.Errorf("failed to extend CEL env: %w", err)
+			}
+			continue
+		}
+
 		variableType, err := validateVariable(compiler, index, variable)
 		if err != nil {
 			result = multierror.Append(result, err)
@@ -141,17 +211,58 @@ func ValidateSettings(input []byte) ([]byte, error) {
 		}
 	}
 
+	validationNames := map[string]bool{}
 	for index, validation := range settings.Validations {
 		if err := validateValidations(compiler, index, validation); err != nil {
 			result = multierror.Append(result, err)
 		}
+
+		if validation.Name == "" {
+			continue
+		}
+		if validationNames[validation.Name] {
+			result = multierror.Append(result, newDuplicateValueError(fmt.Sprintf("validations[%d].name", index), validation.Name))
+		}
+		validationNames[validation.Name] = true
+	}
+
+	var warnings []string
+	for _, w := range lint(compiler, graph, settings) {
+		if settings.Strict {
+			result = multierror.Append(result, w.asError())
+			continue
+		}
+		warnings = append(warnings, w.String())
 	}
 
 	if result != nil {
-		return kubewarden.RejectSettings(kubewarden.Message(fmt.Sprintf("The settings are invalid: %s", result)))
+		return rejectSettings(fmt.Sprintf("The settings are invalid: %s", result), fieldErrors(result), warnings)
 	}
 
-	return kubewarden.AcceptSettings()
+	return acceptSettings(warnings)
+}
+
+func validateTrustRoots(trustRoots []library.SigstoreTrustRoot) error {
+	var result error
+
+	names := map[string]bool{}
+	for index, trustRoot := range trustRoots {
+		if strings.TrimSpace(trustRoot.Name) == "" {
+			err := newRequiredValueError(fmt.Sprintf("trustRoots[%d].name", index), "name is not specified")
+			result = multierror.Append(result, err)
+		} else if names[trustRoot.Name] {
+			err := newDuplicateValueError(fmt.Sprintf("trustRoots[%d].name", index), trustRoot.Name)
+			result = multierror.Append(result, err)
+		}
+		names[trustRoot.Name] = true
+
+		if err := trustRoot.Validate(); err != nil {
+			err := newInvalidValueError(fmt.Sprintf("trustRoots[%d]", index), trustRoot.Name, err.Error())
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
 }
 
 func validateParams(settings Settings) error {
@@ -191,16 +302,7 @@ func isParameterNotFoundActionInvalid(paramRef *admissionregistration.ParamRef)
 func validateVariable(compiler *cel.Compiler, index int, variable Variable) (*types.Type, error) {
 	var result error
 
-	name := strings.TrimSpace(variable.Name)
-	switch {
-	case len(name) == 0:
-		err := newRequiredValueError(fmt.Sprintf("variables[%d].name", index), "name is not specified")
-		result = multierror.Append(result, err)
-	case !cel.IsCELIdentifier(variable.Name):
-		err := newInvalidValueError(fmt.Sprintf("variables[%d].name", index), variable.Name, "name is not a valid CEL identifier")
-		result = multierror.Append(result, err)
-	case name == "params":
-		err := newInvalidValueError(fmt.Sprintf("variables[%d].name", index), variable.Name, "'params' name is not allowed. It conflicts with 'params' from the policy paramaters configuration")
+	if err := validateVariableName(index, variable); err != nil {
 		result = multierror.Append(result, err)
 	}
 
@@ -212,7 +314,7 @@ func validateVariable(compiler *cel.Compiler, index int, variable Variable) (*ty
 	} else {
 		ast, err := compiler.CompileCELExpression(variable.Expression)
 		if err != nil {
-			result = multierror.Append(result, newInvalidValueError(fmt.Sprintf("variables[%d].expression", index), variable.Expression, err.Error()))
+			result = multierror.Append(result, newInvalidExpressionError(fmt.Sprintf("variables[%d].expression", index), variable.Expression, err))
 
 			return nil, result
 		}
@@ -222,6 +324,20 @@ func validateVariable(compiler *cel.Compiler, index int, variable Variable) (*ty
 	return variableType, result
 }
 
+func validateVariableName(index int, variable Variable) error {
+	name := strings.TrimSpace(variable.Name)
+	switch {
+	case len(name) == 0:
+		return newRequiredValueError(fmt.Sprintf("variables[%d].name", index), "name is not specified")
+	case !cel.IsCELIdentifier(variable.Name):
+		return newInvalidValueError(fmt.Sprintf("variables[%d].name", index), variable.Name, "name is not a valid CEL identifier")
+	case name == "params":
+		return newInvalidValueError(fmt.Sprintf("variables[%d].name", index), variable.Name, "'params' name is not allowed. It conflicts with 'params' from the policy paramaters configuration")
+	}
+
+	return nil
+}
+
 func validateValidations(compiler *cel.Compiler, index int, validation Validation) error {
 	var result error
 
@@ -229,12 +345,21 @@ func validateValidations(compiler *cel.Compiler, index int, validation Validatio
 	trimmedMsg := strings.TrimSpace(validation.Message)
 	trimmedMessageExpression := strings.TrimSpace(validation.MessageExpression)
 
+	// the name is part of the comma separated list of the failed validations
+	// reported in the audit annotations
+	if validation.Name != "" {
+		if errs := k8svalidation.IsQualifiedName(validation.Name); len(errs) > 0 {
+			err := newInvalidValueError(fmt.Sprintf("validations[%d].name", index), validation.Name, strings.Join(errs, ", "))
+			result = multierror.Append(result, err)
+		}
+	}
+
 	if len(trimmedExpression) == 0 {
 		err := newRequiredValueError(fmt.Sprintf("validations[%d].expression", index), "expression is not specified")
 		result = multierror.Append(result, err)
 	} else {
 		if e := compiler.ValidateBoolExpression(validation.Expression); e != nil {
-			err := newInvalidValueError(fmt.Sprintf("validations[%d].expression", index), validation.Expression, e.Error())
+			err := newInvalidExpressionError(fmt.Sprintf("validations[%d].expression", index), validation.Expression, e)
 			result = multierror.Append(result, err)
 		}
 	}
@@ -246,7 +371,7 @@ func validateValidations(compiler *cel.Compiler, index int, validation Validatio
 		// use validation.MessageExpression instead of trimmedMessageExpression so that
 		// the compiler output shows the correct column.
 		if err := compiler.ValidateStringExpression(validation.MessageExpression); err != nil {
-			err := newInvalidValueError(fmt.Sprintf("validations[%d].messageExpression", index), validation.MessageExpression, err.Error())
+			err := newInvalidExpressionError(fmt.Sprintf("validations[%d].messageExpression", index), validation.MessageExpression, err)
 			result = multierror.Append(result, err)
 		}
 	}
@@ -267,6 +392,11 @@ func validateValidations(compiler *cel.Compiler, index int, validation Validatio
 		result = multierror.Append(result, err)
 	}
 
+	if validation.Severity != "" && !slices.Contains(supportedSeverities, validation.Severity) {
+		err := newNotSupportedValueError(fmt.Sprintf("validations[%d].severity", index), validation.Severity)
+		result = multierror.Append(result, err)
+	}
+
 	return result
 }
 
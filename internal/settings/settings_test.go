@@ -6,6 +6,8 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/cel/library"
 	"github.com/kubewarden/policy-sdk-go/protocol"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -188,7 +190,49 @@ func TestValidateSettings(t *testing.T) {
 					},
 				},
 			},
-			expectedError: `variables[1].expression: Invalid value: "variables.foo + 1": ERROR: <input>:1:10: undefined field 'foo'`,
+			expectedError: `variables[1].expression: Invalid value: "variables.foo + 1": variable 'foo' is referenced before its declaration at variables[2]; suggested order: correct, foo, bar`,
+		},
+		{
+			name: "variables cyclic reference",
+			settings: Settings{
+				Variables: []Variable{
+					{
+						Name:       "foo",
+						Expression: "variables.bar + 1",
+					},
+					{
+						Name:       "bar",
+						Expression: "variables.baz + 1",
+					},
+					{
+						Name:       "baz",
+						Expression: "variables.foo + 1",
+					},
+				},
+				Validations: []Validation{
+					{
+						Expression: "variables.foo > 1",
+					},
+				},
+			},
+			expectedError: `variables[0].expression: Invalid value: "variables.bar + 1": cyclic reference between variables: foo -> bar -> baz -> foo`,
+		},
+		{
+			name: "variable self reference",
+			settings: Settings{
+				Variables: []Variable{
+					{
+						Name:       "foo",
+						Expression: "variables.foo + 1",
+					},
+				},
+				Validations: []Validation{
+					{
+						Expression: "true",
+					},
+				},
+			},
+			expectedError: `variables[0].expression: Invalid value: "variables.foo + 1": cyclic reference between variables: foo -> foo`,
 		},
 		{
 			name: "invalid ParamKind",
@@ -312,6 +356,159 @@ func TestValidateSettings(t *testing.T) {
 			},
 			expectedError: `parameterNotFoundAction must be 'Deny' or 'Allow' if paramRef is specified`,
 		},
+		{
+			name: "unsupported severity",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "object.spec.replicas < 5",
+						Severity:   "urgent",
+					},
+				},
+			},
+			expectedError: `validations[0].severity: Unsupported value: "urgent"`,
+		},
+		{
+			name: "duplicate variable names",
+			settings: Settings{
+				Variables: []Variable{
+					{
+						Name:       "replicas",
+						Expression: "object.spec.replicas",
+					},
+					{
+						Name:       "replicas",
+						Expression: "object.spec.replicas",
+					},
+				},
+				Validations: []Validation{
+					{
+						Expression: "variables.replicas < 5",
+					},
+				},
+			},
+			expectedError: `variables[1].name: Duplicate value: "replicas"`,
+		},
+		{
+			name: "duplicate validation names",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Name:       "replicas",
+						Expression: "object.spec.replicas < 5",
+					},
+					{
+						Name:       "replicas",
+						Expression: "object.spec.replicas > 1",
+					},
+				},
+			},
+			expectedError: `validations[1].name: Duplicate value: "replicas"`,
+		},
+		{
+			name: "invalid validation name",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Name:       "max,replicas",
+						Expression: "object.spec.replicas < 5",
+					},
+				},
+			},
+			expectedError: `validations[0].name: Invalid value: "max,replicas": name part must consist of alphanumeric characters`,
+		},
+		{
+			name: "invalid label selector literal",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "size(kw.k8s.apiVersion('v1').kind('Pod').labelSelector('app in nginx').list().items) < 5",
+					},
+				},
+			},
+			expectedError: `validations[0].expression: Invalid value: "size(kw.k8s.apiVersion('v1').kind('Pod').labelSelector('app in nginx').list().items) < 5": ERROR: <input>:1:56: invalid label selector: unable to parse requirement: found 'nginx' expected: '('`,
+		},
+		{
+			name: "undefined OCI manifest field",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "kw.oci.image(object.spec.containers[0].image).manifest().image.layerz.size() < 10",
+					},
+				},
+			},
+			expectedError: `validations[0].expression: Invalid value: "kw.oci.image(object.spec.containers[0].image).manifest().image.layerz.size() < 10": ERROR: <input>:1:63: undefined field 'layerz'`,
+		},
+		{
+			name: "undefined certificate field",
+			settings: Settings{
+				Validations: []Validation{
+					{
+						Expression: "kw.crypto.parseCertificate(string(base64.decode(object.data['tls.crt']))).dnsName.size() > 0",
+					},
+				},
+			},
+			expectedError: `validations[0].expression: Invalid value: "kw.crypto.parseCertificate(string(base64.decode(object.data['tls.crt']))).dnsName.size() > 0": ERROR: <input>:1:74: undefined field 'dnsName'`,
+		},
+		{
+			name: "unknown trust root",
+			settings: Settings{
+				TrustRoots: []library.SigstoreTrustRoot{
+					{Name: "release-keys", Keyless: []library.SigstoreKeyless{{Issuer: "https://token.actions.githubusercontent.com", Subject: "release"}}},
+				},
+				Validations: []Validation{
+					{
+						Expression: "kw.sigstore.image(object.spec.containers[0].image).trustRoot('releases').verify().isTrusted()",
+					},
+				},
+			},
+			expectedError: `validations[0].expression: Invalid value: "kw.sigstore.image(object.spec.containers[0].image).trustRoot('releases').verify().isTrusted()": ERROR: <input>:1:62: unknown trust root 'releases'`,
+		},
+		{
+			name: "trust root without verifier",
+			settings: Settings{
+				TrustRoots:  []library.SigstoreTrustRoot{{Name: "release-keys"}},
+				Validations: []Validation{{Expression: "true"}},
+			},
+			expectedError: `trustRoots[0]: Invalid value: "release-keys": exactly one of pubKeys, keyless, keylessPrefix, githubAction and certificate must be set`,
+		},
+		{
+			name: "trust root with many verifiers",
+			settings: Settings{
+				TrustRoots: []library.SigstoreTrustRoot{
+					{Name: "release-keys", GitHubAction: &library.SigstoreGitHubAction{Owner: "kubewarden"}, Keyless: []library.SigstoreKeyless{{Issuer: "issuer", Subject: "subject"}}},
+				},
+				Validations: []Validation{{Expression: "true"}},
+			},
+			expectedError: `trustRoots[0]: Invalid value: "release-keys": exactly one of pubKeys, keyless, keylessPrefix, githubAction and certificate must be set`,
+		},
+		{
+			name: "trust root with invalid public key",
+			settings: Settings{
+				TrustRoots:  []library.SigstoreTrustRoot{{Name: "release-keys", PubKeys: []string{"key"}}},
+				Validations: []Validation{{Expression: "true"}},
+			},
+			expectedError: `trustRoots[0]: Invalid value: "release-keys": pubKeys[0] is not a PEM encoded key`,
+		},
+		{
+			name: "trust root without name",
+			settings: Settings{
+				TrustRoots:  []library.SigstoreTrustRoot{{GitHubAction: &library.SigstoreGitHubAction{Owner: "kubewarden"}}},
+				Validations: []Validation{{Expression: "true"}},
+			},
+			expectedError: `trustRoots[0].name: Required value: name is not specified`,
+		},
+		{
+			name: "duplicated trust root",
+			settings: Settings{
+				TrustRoots: []library.SigstoreTrustRoot{
+					{Name: "release-keys", GitHubAction: &library.SigstoreGitHubAction{Owner: "kubewarden"}},
+					{Name: "release-keys", GitHubAction: &library.SigstoreGitHubAction{Owner: "other"}},
+				},
+				Validations: []Validation{{Expression: "true"}},
+			},
+			expectedError: `trustRoots[1].name: Duplicate value: "release-keys"`,
+		},
 		{
 			name: "failurePolicy allow values",
 			settings: Settings{
@@ -371,3 +568,134 @@ func TestAssignDefaultValueWhenUnmarshalling(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, admissionregistration.Fail, settings.FailurePolicy)
 }
+
+func TestUnreachableVariablesWarnings(t *testing.T) {
+	settings := Settings{
+		Variables: []Variable{
+			{
+				Name:       "replicas",
+				Expression: "object.spec.replicas",
+			},
+			{
+				Name:       "maxReplicas",
+				Expression: "5",
+			},
+			{
+				Name:       "unused",
+				Expression: "variables.replicas + 1",
+			},
+			{
+				Name:       "name",
+				Expression: "object.metadata.name",
+			},
+		},
+		Validations: []Validation{
+			{
+				Expression:        "variables.replicas <= variables.maxReplicas",
+				MessageExpression: "variables.name + ' has too many replicas'",
+			},
+		},
+	}
+	payload, err := json.Marshal(settings)
+	require.NoError(t, err)
+
+	response, err := ValidateSettings(payload)
+	require.NoError(t, err)
+
+	settingsValidationResponse := SettingsValidationResponse{}
+	err = json.Unmarshal(response, &settingsValidationResponse)
+	require.NoError(t, err)
+
+	assert.True(t, settingsValidationResponse.Valid)
+	assert.Equal(t, []string{"variables[2].name: variable 'unused' is not used by any validation"}, settingsValidationResponse.Warnings)
+}
+
+func TestValidateSettingsFieldErrors(t *testing.T) {
+	settings := Settings{
+		Validations: []Validation{
+			{
+				Expression: "true",
+				Reason:     "other",
+			},
+			{
+				Expression:        "true",
+				MessageExpression: "'replicas: ' +\n  object.spec.replicas.size()",
+			},
+		},
+	}
+	payload, err := json.Marshal(settings)
+	require.NoError(t, err)
+
+	response, err := ValidateSettings(payload)
+	require.NoError(t, err)
+
+	settingsValidationResponse := SettingsValidationResponse{}
+	err = json.Unmarshal(response, &settingsValidationResponse)
+	require.NoError(t, err)
+
+	assert.False(t, settingsValidationResponse.Valid)
+	assert.Equal(t, []FieldError{
+		{
+			Field: "validations[0].reason",
+			Type:  ErrorTypeNotSupported,
+			Value: "other",
+		},
+		{
+			Field:  "validations[1].messageExpression",
+			Type:   ErrorTypeInvalid,
+			Value:  "'replicas: ' +\n  object.spec.replicas.size()",
+			Detail: "found no matching overload for '_+_' applied to '(string, int)'",
+			Position: &cel.Issue{
+				Line:    1,
+				Column:  14,
+				Message: "found no matching overload for '_+_' applied to '(string, int)'",
+				Snippet: " | 'replicas: ' +\n | .............^",
+			},
+		},
+	}, settingsValidationResponse.Errors)
+}
+
+func TestValidateSettingsVariableReferenceErrors(t *testing.T) {
+	settings := Settings{
+		Variables: []Variable{
+			{
+				Name:       "foo",
+				Expression: "variables.bar + 1",
+			},
+			{
+				Name:       "bar",
+				Expression: "variables.foo + 1",
+			},
+			{
+				Name:       "params", // reserved name, referencing a variable declared later
+				Expression: "variables.baz",
+			},
+			{
+				Name:       "baz",
+				Expression: "1",
+			},
+		},
+		Validations: []Validation{
+			{
+				Expression: "variables.foo > 1 && variables.baz > 0",
+			},
+		},
+	}
+	payload, err := json.Marshal(settings)
+	require.NoError(t, err)
+
+	response, err := ValidateSettings(payload)
+	require.NoError(t, err)
+
+	settingsValidationResponse := SettingsValidationResponse{}
+	err = json.Unmarshal(response, &settingsValidationResponse)
+	require.NoError(t, err)
+
+	// the validation referencing foo is not reported as referencing an undefined variable
+	assert.False(t, settingsValidationResponse.Valid)
+	fields := make([]string, 0, len(settingsValidationResponse.Errors))
+	for _, fieldError := range settingsValidationResponse.Errors {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"variables[0].expression", "variables[2].expression", "variables[2].name"}, fields)
+}
@@ -0,0 +1,240 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/kubewarden/cel-policy/internal/cel"
+)
+
+// variableGraph is the dependency graph of the policy variables.
+// Each node is the index of a variable inside of the settings, and each edge
+// points to a variable referenced by the expression of the node.
+type variableGraph struct {
+	variables []Variable
+	// indexes maps the name of a variable to its position in the settings.
+	indexes map[string]int
+	// dependencies holds the indexes of the variables referenced by each variable,
+	// in order of appearance.
+	dependencies [][]int
+}
+
+// newVariableGraph builds the dependency graph of the variables by looking at the
+// `variables.<name>` references of their parsed expressions. References to unknown
+// variables are ignored, they are reported by the compiler.
+// Variables whose expression cannot be parsed have no dependencies.
+func newVariableGraph(compiler *cel.Compiler, variables []Variable) *variableGraph {
+	graph := &variableGraph{
+		variables:    variables,
+		indexes:      make(map[string]int, len(variables)),
+		dependencies: make([][]int, len(variables)),
+	}
+
+	for index, variable := range variables {
+		if _, found := graph.indexes[variable.Name]; !found {
+			graph.indexes[variable.Name] = index
+		}
+	}
+
+	for index, variable := range variables {
+		graph.dependencies[index] = graph.references(compiler, variable.Expression)
+	}
+
+	return graph
+}
+
+// references returns the indexes of the variables referenced by the expression.
+func (g *variableGraph) references(compiler *cel.Compiler, expression string) []int {
+	ast, err := compiler.ParseCELExpression(expression)
+	if err != nil {
+		return nil
+	}
+
+	var references []int
+	for _, name := range cel.ReferencedVariables(ast) {
+		if index, found := g.indexes[name]; found {
+			references = append(references, index)
+		}
+	}
+
+	return references
+}
+
+// validate reports the cyclic references between variables and the references
+// to variables declared later in the settings.
+// Variables can only reference variables declared before them, the same as in
+// the Kubernetes ValidatingAdmissionPolicy. When no cycle is found, the error
+// suggests an order of declaration that satisfies all the references.
+// The returned slice has the indexes of the variables that cannot be compiled
+// because of the reported errors.
+func (g *variableGraph) validate() ([]int, error) {
+	var result error
+	var invalid []int
+
+	cycles := g.cycles()
+	for _, cycle := range cycles {
+		first := slices.Min(cycle)
+		names := make([]string, 0, len(cycle)+1)
+		for _, index := range cycle {
+			names = append(names, g.variables[index].Name)
+		}
+		names = append(names, names[0])
+
+		err := newInvalidValueError(fmt.Sprintf("variables[%d].expression", first), g.variables[first].Expression, fmt.Sprintf("cyclic reference between variables: %s", strings.Join(names, " -> ")))
+		result = multierror.Append(result, err)
+		invalid = append(invalid, cycle...)
+	}
+
+	var suggestion string
+	if len(cycles) == 0 {
+		suggestion = fmt.Sprintf("; suggested order: %s", strings.Join(g.names(g.sort()), ", "))
+	}
+
+	for index, dependencies := range g.dependencies {
+		if slices.Contains(invalid, index) {
+			continue
+		}
+
+		for _, dependency := range dependencies {
+			if dependency <= index || slices.Contains(invalid, dependency) {
+				continue
+			}
+
+			err := newInvalidValueError(fmt.Sprintf("variables[%d].expression", index), g.variables[index].Expression, fmt.Sprintf("variable '%s' is referenced before its declaration at variables[%d]%s", g.variables[dependency].Name, dependency, suggestion))
+			result = multierror.Append(result, err)
+			invalid = append(invalid, index)
+
+			break
+		}
+	}
+
+	return invalid, result
+}
+
+// cycles returns the cycles of the graph, each one as the path of variable
+// indexes starting from the first variable visited.
+// Every cycle is reported only once, regardless of the variable it is entered from.
+func (g *variableGraph) cycles() [][]int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(g.variables))
+	var path []int
+	var cycles [][]int
+	seen := map[string]bool{}
+
+	var visit func(index int)
+	visit = func(index int) {
+		state[index] = visiting
+		path = append(path, index)
+
+		for _, dependency := range g.dependencies[index] {
+			switch state[dependency] {
+			case unvisited:
+				visit(dependency)
+			case visiting:
+				start := slices.Index(path, dependency)
+				cycle := slices.Clone(path[start:])
+
+				members := slices.Clone(cycle)
+				slices.Sort(members)
+				key := fmt.Sprint(members)
+				if !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[index] = visited
+	}
+
+	for index := range g.variables {
+		if state[index] == unvisited {
+			visit(index)
+		}
+	}
+
+	return cycles
+}
+
+// sort returns the variable indexes sorted so that every variable comes after
+// the variables it references. The relative order of the settings is preserved
+// whenever possible.
+// The graph must not have cycles.
+func (g *variableGraph) sort() []int {
+	sorted := make([]int, 0, len(g.variables))
+	placed := make([]bool, len(g.variables))
+
+	for len(sorted) < len(g.variables) {
+		for index, dependencies := range g.dependencies {
+			if placed[index] {
+				continue
+			}
+
+			ready := true
+			for _, dependency := range dependencies {
+				if !placed[dependency] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				sorted = append(sorted, index)
+				placed[index] = true
+				break
+			}
+		}
+	}
+
+	return sorted
+}
+
+// unreachable returns the indexes of the variables that are not referenced,
+// directly or through other variables, by the given expressions.
+func (g *variableGraph) unreachable(compiler *cel.Compiler, expressions []string) []int {
+	reachable := make([]bool, len(g.variables))
+
+	var visit func(index int)
+	visit = func(index int) {
+		if reachable[index] {
+			return
+		}
+		reachable[index] = true
+
+		for _, dependency := range g.dependencies[index] {
+			visit(dependency)
+		}
+	}
+
+	for _, expression := range expressions {
+		for _, index := range g.references(compiler, expression) {
+			visit(index)
+		}
+	}
+
+	var unreachable []int
+	for index := range g.variables {
+		if !reachable[index] {
+			unreachable = append(unreachable, index)
+		}
+	}
+
+	return unreachable
+}
+
+func (g *variableGraph) names(indexes []int) []string {
+	names := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		names = append(names, g.variables[index].Name)
+	}
+
+	return names
+}
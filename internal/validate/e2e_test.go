@@ -0,0 +1,129 @@
+package validate
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/kubewarden/cel-policy/internal/hostreplay"
+	kubewardenProtocol "github.com/kubewarden/policy-sdk-go/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestE2E runs the scenarios of e2e.bats replaying the host capabilities
+// interactions recorded in the kwctl session files.
+func TestE2E(t *testing.T) {
+	tests := []struct {
+		name                       string
+		session                    string
+		request                    string
+		settings                   string
+		expectedValidationResponse kubewardenProtocol.ValidationResponse
+		expectedError              string
+	}{
+		{
+			name:     "accept",
+			session:  "session.yaml",
+			request:  "deployment_lte_max_replicas.json",
+			settings: "settings.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: true,
+			},
+		},
+		{
+			name:     "reject",
+			session:  "session.yaml",
+			request:  "deployment_gt_max_replicas.json",
+			settings: "settings.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: false,
+				Message:  message("Deployment: nginx, namespace: default - replicas must be no greater than 50"),
+				Code:     code(401),
+			},
+		},
+		{
+			name:     "reject using params with name",
+			session:  "session_params.yaml",
+			request:  "deployment_gt_max_replicas.json",
+			settings: "settings_params.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: false,
+				Message:  message("Deployment: nginx, namespace: default - replicas must be no greater than 50"),
+				Code:     code(401),
+			},
+		},
+		{
+			name:     "reject using params with selector",
+			session:  "session_params_selector.yaml",
+			request:  "deployment_gt_max_replicas.json",
+			settings: "settings_params_selector.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: false,
+				Message:  message("Deployment: nginx, namespace: default - replicas must be no greater than 40"),
+				Code:     code(401),
+			},
+		},
+		{
+			name:     "reject using params with selector returning empty list",
+			session:  "session_params_selector_empty_params.yaml",
+			request:  "deployment_gt_max_replicas.json",
+			settings: "settings_params_selector.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: false,
+				Message:  message("failed to get params for performing policy evaluation: no parameters found"),
+				Code:     code(400),
+			},
+		},
+		{
+			name:     "accept using params with selector returning empty list when failurePolicy is ignore",
+			session:  "session_params_selector_empty_params.yaml",
+			request:  "deployment_gt_max_replicas.json",
+			settings: "settings_params_selector_ignore_failure.json",
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: true,
+			},
+		},
+		{
+			name:          "reject because of type error",
+			session:       "session.yaml",
+			request:       "deployment_gt_max_replicas.json",
+			settings:      "settings_type_error.json",
+			expectedError: "found no matching overload for '_+_' applied to '(string, int)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := hostreplay.Load("../../test_data/" + test.session)
+			require.NoError(t, err)
+
+			setHostClient(t, session)
+
+			request, err := os.ReadFile("../../test_data/" + test.request)
+			require.NoError(t, err)
+			settings, err := os.ReadFile("../../test_data/" + test.settings)
+			require.NoError(t, err)
+
+			payload, err := json.Marshal(map[string]json.RawMessage{
+				"request":  request,
+				"settings": settings,
+			})
+			require.NoError(t, err)
+
+			responsePayload, err := Validate(payload)
+			if test.expectedError != "" {
+				require.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Empty(t, session.Unmatched())
+
+			var response kubewardenProtocol.ValidationResponse
+			err = json.Unmarshal(responsePayload, &response)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedValidationResponse, response)
+		})
+	}
+}
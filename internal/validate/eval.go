@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/cel/library"
+	"github.com/kubewarden/cel-policy/internal/settings"
+	"github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// EvalRequest holds the expression to evaluate against an admission request.
+// The expression is either given directly, or it is the expression of one of
+// the variables or validations of the settings.
+type EvalRequest struct {
+	Request  json.RawMessage
+	Settings settings.Settings
+	// Expression is the CEL expression to evaluate.
+	Expression string
+	// Variable is the name of the variable to evaluate.
+	Variable string
+	// Validation is the name or the index of the validation to evaluate.
+	Validation string
+	// Explain reports the sub-expressions that evaluated to false, when the
+	// expression evaluates to false.
+	Explain bool
+}
+
+// EvalResponse is the result of the evaluation of an expression.
+type EvalResponse struct {
+	Expression string `json:"expression"`
+	Value      any    `json:"value"`
+	Type       string `json:"type,omitempty"`
+	// Explanation holds the failing sub-expressions, when explain is requested.
+	Explanation []string `json:"explanation,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Eval evaluates an expression against an admission request, using the same
+// CEL environment of the policy. The variables of the settings are available
+// to the expression, as well as the params when configured.
+// Errors happening during the compilation or the evaluation of the expression
+// are part of the response.
+func Eval(evalRequest EvalRequest) ([]byte, error) {
+	library.ResetHostCache()
+
+	expression, err := evalRequest.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.KubernetesAdmissionRequest{}
+	if err = json.Unmarshal(evalRequest.Request, &request); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal request: %w", err)
+	}
+
+	compiler, err := cel.NewCompiler(evalRequest.Settings.TrustRoots...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL compiler: %w", err)
+	}
+
+	vars, requestMap, err := buildVars(request, evalRequest.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	response := EvalResponse{Expression: expression}
+
+	validationRequest := ValidationRequest{Request: evalRequest.Request, Settings: evalRequest.Settings}
+	paramsList, err := getEvaluationParams(validationRequest, requestMap)
+	if err != nil {
+		response.Error = fmt.Sprintf("failed to get params: %s", err)
+		return json.Marshal(response)
+	}
+	if len(paramsList) > 0 {
+		// only the first params are used, the same expression would be evaluated against every params
+		vars["params"] = func() ref.Val {
+			return types.NewDynamicMap(types.DefaultTypeAdapter, paramsList[0])
+		}
+	}
+
+	if err = evalVariables(compiler, vars, evalRequest.Settings.Variables); err != nil {
+		response.Error = fmt.Sprintf("failed to compile variables: %s", err)
+		return json.Marshal(response)
+	}
+
+	ast, err := compiler.CompileCELExpression(expression)
+	if err != nil {
+		response.Error = err.Error()
+		return json.Marshal(response)
+	}
+
+	var val ref.Val
+	if evalRequest.Explain {
+		val, response.Explanation, err = compiler.ExplainCELExpression(vars, ast)
+	} else {
+		val, err = compiler.EvalCELExpression(vars, ast)
+	}
+	if err != nil {
+		response.Error = err.Error()
+		return json.Marshal(response)
+	}
+
+	response.Value = cel.NativeValue(val)
+	response.Type = val.Type().TypeName()
+
+	return json.Marshal(response)
+}
+
+// expression returns the expression to evaluate.
+func (r *EvalRequest) expression() (string, error) {
+	switch {
+	case r.Expression != "":
+		return r.Expression, nil
+	case r.Variable != "":
+		index := slices.IndexFunc(r.Settings.Variables, func(variable settings.Variable) bool {
+			return variable.Name == r.Variable
+		})
+		if index == -1 {
+			return "", fmt.Errorf("variable '%s' not found in settings", r.Variable)
+		}
+		return r.Settings.Variables[index].Expression, nil
+	case r.Validation != "":
+		index := slices.IndexFunc(r.Settings.Validations, func(validation settings.Validation) bool {
+			return validation.Name == r.Validation
+		})
+		if index == -1 {
+			var err error
+			index, err = strconv.Atoi(r.Validation)
+			if err != nil || index < 0 || index >= len(r.Settings.Validations) {
+				return "", fmt.Errorf("validation '%s' not found in settings", r.Validation)
+			}
+		}
+		return r.Settings.Validations[index].Expression, nil
+	default:
+		return "", errors.New("either an expression, a variable or a validation must be provided")
+	}
+}
@@ -0,0 +1,124 @@
+package validate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kubewarden/cel-policy/internal/settings"
+	kubewardenProtocol "github.com/kubewarden/policy-sdk-go/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	policySettings := settings.Settings{
+		Variables: []settings.Variable{
+			{
+				Name:       "replicas",
+				Expression: "object.spec.replicas",
+			},
+		},
+		Validations: []settings.Validation{
+			{
+				Name:       "max-replicas",
+				Expression: "variables.replicas <= 5",
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		evalRequest      EvalRequest
+		expectedResponse EvalResponse
+	}{
+		{
+			name:        "expression",
+			evalRequest: EvalRequest{Expression: "object.metadata.labels"},
+			expectedResponse: EvalResponse{
+				Expression: "object.metadata.labels",
+				Value:      map[string]any{"app": "nginx"},
+				Type:       "map",
+			},
+		},
+		{
+			name:        "variable",
+			evalRequest: EvalRequest{Variable: "replicas", Settings: policySettings},
+			expectedResponse: EvalResponse{
+				Expression: "object.spec.replicas",
+				Value:      float64(10),
+				Type:       "double",
+			},
+		},
+		{
+			name:        "validation",
+			evalRequest: EvalRequest{Validation: "0", Settings: policySettings},
+			expectedResponse: EvalResponse{
+				Expression: "variables.replicas <= 5",
+				Value:      false,
+				Type:       "bool",
+			},
+		},
+		{
+			name:        "validation by name",
+			evalRequest: EvalRequest{Validation: "max-replicas", Settings: policySettings},
+			expectedResponse: EvalResponse{
+				Expression: "variables.replicas <= 5",
+				Value:      false,
+				Type:       "bool",
+			},
+		},
+		{
+			name:        "explain",
+			evalRequest: EvalRequest{Validation: "0", Settings: policySettings, Explain: true},
+			expectedResponse: EvalResponse{
+				Expression:  "variables.replicas <= 5",
+				Value:       false,
+				Type:        "bool",
+				Explanation: []string{"variables.replicas <= 5 is false (variables.replicas = 10)"},
+			},
+		},
+		{
+			name:        "evaluation error",
+			evalRequest: EvalRequest{Expression: "object.metadata.annotations"},
+			expectedResponse: EvalResponse{
+				Expression: "object.metadata.annotations",
+				Error:      "no such key: annotations",
+			},
+		},
+	}
+
+	object := map[string]any{
+		"metadata": map[string]any{
+			"name":   "nginx",
+			"labels": map[string]string{"app": "nginx"},
+		},
+		"spec": map[string]any{
+			"replicas": 10,
+		},
+	}
+	objectPayload, err := json.Marshal(object)
+	require.NoError(t, err)
+
+	request, err := json.Marshal(kubewardenProtocol.KubernetesAdmissionRequest{Object: objectPayload})
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.evalRequest.Request = request
+
+			response, err := Eval(test.evalRequest)
+			require.NoError(t, err)
+
+			evalResponse := EvalResponse{}
+			err = json.Unmarshal(response, &evalResponse)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedResponse, evalResponse)
+		})
+	}
+}
+
+func TestEvalUnknownVariable(t *testing.T) {
+	_, err := Eval(EvalRequest{Variable: "foo"})
+	require.ErrorContains(t, err, "variable 'foo' not found in settings")
+}
@@ -7,38 +7,39 @@ import (
 
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/cel-policy/internal/cel/library"
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
 	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	labels "k8s.io/apimachinery/pkg/labels"
-	selection "k8s.io/apimachinery/pkg/selection"
 )
 
-var (
-	host                = capabilities.NewHost()
-	namespaceObjectData map[string]interface{}
-)
+// host shares the client of the CEL libraries, so that the host calls are
+// memoized by the same request-scoped cache.
+//
+//nolint:gochecknoglobals // the client is shared with the CEL libraries
+var host = capabilities.Host{Client: library.HostClient()}
+
+// SetHostClient replaces the client used to interact with the policy host,
+// by the policy and by the CEL libraries.
+// This allows to run the policy outside of the policy host, for example when
+// testing policies. The data cached from the previous host is discarded.
+// It returns the previous client, so that it can be restored.
+func SetHostClient(client capabilities.WapcClient) capabilities.WapcClient {
+	previous := library.SetHostClient(client)
+	host.Client = library.HostClient()
+
+	return previous
+}
 
+// getNamespaceObject returns the namespace of the request, sharing the
+// request-scoped cache of the `kw.k8s.namespaceObject` function.
 func getNamespaceObject(name string) ref.Val {
-	if namespaceObjectData == nil {
-		resourceRequest := kubernetes.GetResourceRequest{
-			APIVersion: "v1",
-			Kind:       "Namespace",
-			Name:       name,
-		}
-
-		responseBytes, err := kubernetes.GetResource(&host, resourceRequest)
-		if err != nil {
-			return types.NewErr("cannot get namespace data: %s. `namespaceObject` cannot be populated.", err)
-		}
-
-		err = json.Unmarshal(responseBytes, &namespaceObjectData)
-		if err != nil {
-			return types.NewErr("cannot parse namespace data: %w", err)
-		}
+	val := library.NamespaceObject(name)
+	if types.IsError(val) {
+		return types.NewErr("%s. `namespaceObject` cannot be populated.", val)
 	}
 
-	return types.NewDynamicMap(types.DefaultTypeAdapter, namespaceObjectData)
+	return val
 }
 
 func getKubernetesResource(name string, namespace string, apiVersion string, kind string) (any, error) {
@@ -68,7 +69,10 @@ func getKubernetesResourceList(
 	kind string,
 	selector *metav1.LabelSelector,
 ) ([]any, error) {
-	labelSelectorString, err := formatLabelSelectorString(selector)
+	if selector == nil {
+		return nil, errors.New("paramRef.selector is nil")
+	}
+	labelSelectorString, err := library.FormatLabelSelector(selector)
 	if err != nil {
 		return nil, err
 	}
@@ -94,48 +98,3 @@ func getKubernetesResourceList(
 	}
 	return items, nil
 }
-
-// Function used to format a metav1.LabelSelector into a string that can be understood
-// by Kubewarden host capabilities.
-func formatLabelSelectorString(selector *metav1.LabelSelector) (string, error) {
-	if selector == nil {
-		return "", errors.New("paramRef.selector is nil")
-	}
-	labelSelectorBuilder := labels.NewSelector()
-	for key, value := range selector.MatchLabels {
-		requirement, err := labels.NewRequirement(key, selection.Equals, []string{value})
-		if err != nil {
-			return "", err
-		}
-		labelSelectorBuilder = labelSelectorBuilder.Add(*requirement)
-	}
-	for _, matchExpression := range selector.MatchExpressions {
-		operator, err := getOperationString(matchExpression.Operator)
-		if err != nil {
-			return "", err
-		}
-		requirement, err := labels.NewRequirement(matchExpression.Key, operator, matchExpression.Values)
-		if err != nil {
-			return "", err
-		}
-		labelSelectorBuilder = labelSelectorBuilder.Add(*requirement)
-	}
-
-	return labelSelectorBuilder.String(), nil
-}
-
-func getOperationString(op metav1.LabelSelectorOperator) (selection.Operator, error) {
-	if op == metav1.LabelSelectorOpIn {
-		return selection.In, nil
-	}
-	if op == metav1.LabelSelectorOpNotIn {
-		return selection.NotIn, nil
-	}
-	if op == metav1.LabelSelectorOpExists {
-		return selection.Exists, nil
-	}
-	if op == metav1.LabelSelectorOpDoesNotExist {
-		return selection.DoesNotExist, nil
-	}
-	return "", errors.New("unknown label selector operator")
-}
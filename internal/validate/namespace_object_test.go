@@ -1,42 +1,85 @@
 package validate
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/kubewarden/cel-policy/internal/settings"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/mocks"
+	kubewardenProtocol "github.com/kubewarden/policy-sdk-go/protocol"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func TestLabelSelectorParsing(t *testing.T) {
-	labelSelector := k8smetav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"app": "my-app",
-		},
-		MatchExpressions: []k8smetav1.LabelSelectorRequirement{
-			{
-				Key:      "environment",
-				Operator: k8smetav1.LabelSelectorOpIn,
-				Values:   []string{"production", "staging"},
-			},
-			{
-				Key:      "phase",
-				Operator: k8smetav1.LabelSelectorOpNotIn,
-				Values:   []string{"initial", "final"},
-			},
-			{
-				Key:      "foo",
-				Operator: k8smetav1.LabelSelectorOpExists,
-				Values:   []string{},
+func TestNamespaceObjectIsRequestScoped(t *testing.T) {
+	mockWapcClient := &mocks.MockWapcClient{}
+	for _, name := range []string{"default", "other"} {
+		request, err := json.Marshal(kubernetes.GetResourceRequest{APIVersion: "v1", Kind: "Namespace", Name: name})
+		require.NoError(t, err)
+
+		response, err := json.Marshal(&corev1.Namespace{
+			Metadata: &metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"env": name},
 			},
+		})
+		require.NoError(t, err)
+
+		mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(response, nil)
+	}
+	setHostClient(t, mockWapcClient)
+
+	settingsPayload, err := json.Marshal(settings.Settings{
+		Validations: []settings.Validation{
 			{
-				Key:      "bar",
-				Operator: k8smetav1.LabelSelectorOpDoesNotExist,
-				Values:   []string{},
+				Expression:        "namespaceObject.metadata.labels.env == 'default'",
+				MessageExpression: "'namespace: ' + namespaceObject.metadata.labels.env",
 			},
 		},
+	})
+	require.NoError(t, err)
+
+	for _, namespace := range []string{"default", "other", "default"} {
+		object, err := json.Marshal(&corev1.Pod{
+			Metadata: &metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		payload, err := json.Marshal(kubewardenProtocol.ValidationRequest{
+			Request:  kubewardenProtocol.KubernetesAdmissionRequest{Object: object},
+			Settings: settingsPayload,
+		})
+		require.NoError(t, err)
+
+		response, err := Validate(payload)
+		require.NoError(t, err)
+
+		validationResponse := kubewardenProtocol.ValidationResponse{}
+		err = json.Unmarshal(response, &validationResponse)
+		require.NoError(t, err)
+
+		if namespace == "default" {
+			assert.True(t, validationResponse.Accepted)
+		} else {
+			assert.Equal(t, message("namespace: other"), validationResponse.Message)
+		}
 	}
 
-	labelSelectorString, err := formatLabelSelectorString(&labelSelector)
-	require.NoError(t, err)
-	require.Equal(t, "app=my-app,!bar,environment in (production,staging),foo,phase notin (final,initial)", labelSelectorString)
+	// the namespace is fetched once per request
+	mockWapcClient.AssertNumberOfCalls(t, "HostCall", 3)
+}
+
+// setHostClient replaces the host client for the duration of the test.
+func setHostClient(t *testing.T, client capabilities.WapcClient) {
+	t.Helper()
+
+	previous := SetHostClient(client)
+	t.Cleanup(func() { SetHostClient(previous) })
 }
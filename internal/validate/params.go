@@ -9,7 +9,6 @@ import (
 	"github.com/kubewarden/cel-policy/internal/cel"
 	"github.com/kubewarden/cel-policy/internal/settings"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
-	"github.com/kubewarden/policy-sdk-go/protocol"
 	"k8s.io/kubernetes/pkg/apis/admissionregistration"
 )
 
@@ -86,13 +85,14 @@ func evalValidationsAgainstParamsList(
 	vars map[string]any,
 	paramsList []any,
 	validations []settings.Validation,
-) (*protocol.ValidationResponse, error) {
+	explain bool,
+) (*ValidationResponse, error) {
 	for _, params := range paramsList {
 		vars["params"] = func() ref.Val {
 			return types.NewDynamicMap(types.DefaultTypeAdapter, params)
 		}
 
-		response, err := evalValidations(compiler, vars, validations)
+		response, err := evalValidations(compiler, vars, validations, explain)
 		if err != nil {
 			return nil, err
 		}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/kubewarden/cel-policy/internal/cel/library"
 	"github.com/kubewarden/cel-policy/internal/settings"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
@@ -260,7 +261,7 @@ func TestParamFetchBehaviour(t *testing.T) {
 				},
 			}
 
-			expectedLabelSelector, err := formatLabelSelectorString(settings.ParamRef.Selector)
+			expectedLabelSelector, err := library.FormatLabelSelector(settings.ParamRef.Selector)
 			require.NoError(t, err)
 			listRequest, err := json.Marshal(&kubernetes.ListResourcesByNamespaceRequest{
 				APIVersion:    "v1",
@@ -324,7 +325,7 @@ func TestParamFetchBehaviour(t *testing.T) {
 			mockWapcClient.
 				On("HostCall", "kubewarden", "kubernetes", "list_resources_by_namespace", listRequest).Return(listResponse, listResponseError).
 				On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(response, nil)
-			host.Client = mockWapcClient
+			setHostClient(t, mockWapcClient)
 
 			object := corev1.Pod{
 				Metadata: &metav1.ObjectMeta{
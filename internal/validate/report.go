@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/settings"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"k8s.io/kubernetes/pkg/apis/admissionregistration"
+)
+
+// ValidationOutcome is the outcome of the evaluation of a validation.
+type ValidationOutcome string
+
+const (
+	ValidationPass  ValidationOutcome = "pass"
+	ValidationFail  ValidationOutcome = "fail"
+	ValidationError ValidationOutcome = "error"
+)
+
+// ValidationResult is the result of a single validation, returned in report mode.
+type ValidationResult struct {
+	// Name is the name of the validation, if any.
+	Name string `json:"name,omitempty"`
+	// Index is the position of the validation inside of the settings.
+	Index  int               `json:"index"`
+	Result ValidationOutcome `json:"result"`
+	// Message is the rejection message, or the evaluation error.
+	Message  string `json:"message,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
+	// code is the status code of the rejection.
+	code kubewarden.Code
+}
+
+// evalReport evaluates every validation, instead of stopping at the first
+// failure, and returns the result of each one in the response.
+// When params are used, every validation is evaluated against each params,
+// and the worst outcome of the validation is reported.
+// The request is rejected with the message of the first validation that did not pass.
+// With the `Ignore` failure policy, the validations failing to evaluate are
+// reported, but they do not reject the request.
+func evalReport(
+	compiler *cel.Compiler,
+	vars map[string]any,
+	paramsList []any,
+	validations []settings.Validation,
+	explain bool,
+	failurePolicy admissionregistration.FailurePolicyType,
+) *ValidationResponse {
+	if len(paramsList) == 0 {
+		return buildReportResponse(evalValidationResults(compiler, vars, validations, explain), failurePolicy)
+	}
+
+	var results []ValidationResult
+	for _, params := range paramsList {
+		vars["params"] = func() ref.Val {
+			return types.NewDynamicMap(types.DefaultTypeAdapter, params)
+		}
+
+		paramsResults := evalValidationResults(compiler, vars, validations, explain)
+		if results == nil {
+			results = paramsResults
+			continue
+		}
+
+		for index, result := range paramsResults {
+			if outcomeRank(result.Result, failurePolicy) > outcomeRank(results[index].Result, failurePolicy) {
+				results[index] = result
+			}
+		}
+	}
+
+	return buildReportResponse(results, failurePolicy)
+}
+
+func evalValidationResults(compiler *cel.Compiler, vars map[string]any, validations []settings.Validation, explain bool) []ValidationResult {
+	results := make([]ValidationResult, 0, len(validations))
+
+	for index, validation := range validations {
+		result := ValidationResult{
+			Name:     validation.Name,
+			Index:    index,
+			Result:   ValidationPass,
+			Severity: validation.Severity,
+			Category: validation.Category,
+		}
+
+		response, err := evaluateValidation(compiler, vars, validation, explain)
+		switch {
+		case err != nil:
+			result.Result = ValidationError
+			result.Message = err.Error()
+			result.code = httpBadRequestStatusCode
+		case !response.Accepted:
+			result.Result = ValidationFail
+			result.Message = *response.Message
+			result.code = kubewarden.Code(*response.Code)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// buildReportResponse returns the response holding the results of the validations.
+// The names of the validations that did not pass are added to the audit annotations.
+func buildReportResponse(results []ValidationResult, failurePolicy admissionregistration.FailurePolicyType) *ValidationResponse {
+	var response *ValidationResponse
+	var failed []string
+
+	for _, result := range results {
+		if result.Result == ValidationPass {
+			continue
+		}
+		if result.Result == ValidationError && failurePolicy == admissionregistration.Ignore {
+			continue
+		}
+
+		if response == nil {
+			response = buildRejectResponse(kubewarden.Message(result.Message), result.code, "")
+		}
+		if result.Name != "" {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	if response == nil {
+		response = buildAcceptResponse()
+	}
+	if len(failed) > 0 {
+		response.AuditAnnotations = map[string]string{
+			failedValidationsAnnotation: strings.Join(failed, ","),
+		}
+	}
+	response.Report = results
+
+	return response
+}
+
+// outcomeRank orders the outcomes from the best to the worst. With the `Ignore`
+// failure policy, the errors do not reject the request, so a failure is worse.
+func outcomeRank(outcome ValidationOutcome, failurePolicy admissionregistration.FailurePolicyType) int {
+	switch outcome {
+	case ValidationError:
+		if failurePolicy == admissionregistration.Ignore {
+			return 1
+		}
+		return 2 //nolint:mnd // the rank only orders the outcomes
+	case ValidationFail:
+		if failurePolicy == admissionregistration.Ignore {
+			return 2 //nolint:mnd // the rank only orders the outcomes
+		}
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,211 @@
+package validate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/settings"
+	kubewardenProtocol "github.com/kubewarden/policy-sdk-go/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/apis/admissionregistration"
+)
+
+func TestValidateReport(t *testing.T) {
+	validations := []settings.Validation{
+		{
+			Name:       "max-replicas",
+			Expression: "object.spec.replicas <= 5",
+			Message:    "too many replicas",
+			Reason:     settings.StatusReasonForbidden,
+			Severity:   settings.SeverityHigh,
+			Category:   "Resources",
+		},
+		{
+			Name:       "allowed-name",
+			Expression: "object.metadata.name != 'forbidden'",
+		},
+		{
+			Expression: "object.metadata.labels.app == 'nginx'",
+			Severity:   settings.SeverityLow,
+		},
+	}
+
+	tests := []struct {
+		name             string
+		object           map[string]any
+		failurePolicy    admissionregistration.FailurePolicyType
+		expectedResponse ValidationResponse
+	}{
+		{
+			name: "all validations pass",
+			object: map[string]any{
+				"metadata": map[string]any{"name": "nginx", "labels": map[string]any{"app": "nginx"}},
+				"spec":     map[string]any{"replicas": 1},
+			},
+			expectedResponse: ValidationResponse{
+				ValidationResponse: kubewardenProtocol.ValidationResponse{
+					Accepted: true,
+				},
+				Report: []ValidationResult{
+					{Name: "max-replicas", Index: 0, Result: ValidationPass, Severity: "high", Category: "Resources"},
+					{Name: "allowed-name", Index: 1, Result: ValidationPass},
+					{Index: 2, Result: ValidationPass, Severity: "low"},
+				},
+			},
+		},
+		{
+			name: "every validation is evaluated",
+			object: map[string]any{
+				"metadata": map[string]any{"name": "forbidden"},
+				"spec":     map[string]any{"replicas": 10},
+			},
+			expectedResponse: ValidationResponse{
+				ValidationResponse: kubewardenProtocol.ValidationResponse{
+					Accepted: false,
+					Message:  message("max-replicas: too many replicas"),
+					Code:     code(403),
+				},
+				AuditAnnotations: map[string]string{
+					"cel-policy/failed-validations": "max-replicas,allowed-name",
+				},
+				Report: []ValidationResult{
+					{Name: "max-replicas", Index: 0, Result: ValidationFail, Message: "max-replicas: too many replicas", Severity: "high", Category: "Resources"},
+					{Name: "allowed-name", Index: 1, Result: ValidationFail, Message: "allowed-name: failed expression: object.metadata.name != 'forbidden'"},
+					{Index: 2, Result: ValidationError, Message: "failed to evaluate expression: no such key: labels", Severity: "low"},
+				},
+			},
+		},
+		{
+			name: "errors do not reject the request with the Ignore failure policy",
+			object: map[string]any{
+				"metadata": map[string]any{"name": "nginx"},
+				"spec":     map[string]any{"replicas": 1},
+			},
+			failurePolicy: admissionregistration.Ignore,
+			expectedResponse: ValidationResponse{
+				ValidationResponse: kubewardenProtocol.ValidationResponse{
+					Accepted: true,
+				},
+				Report: []ValidationResult{
+					{Name: "max-replicas", Index: 0, Result: ValidationPass, Severity: "high", Category: "Resources"},
+					{Name: "allowed-name", Index: 1, Result: ValidationPass},
+					{Index: 2, Result: ValidationError, Message: "failed to evaluate expression: no such key: labels", Severity: "low"},
+				},
+			},
+		},
+		{
+			name: "errors reject the request with the Fail failure policy",
+			object: map[string]any{
+				"metadata": map[string]any{"name": "nginx"},
+				"spec":     map[string]any{"replicas": 1},
+			},
+			failurePolicy: admissionregistration.Fail,
+			expectedResponse: ValidationResponse{
+				ValidationResponse: kubewardenProtocol.ValidationResponse{
+					Accepted: false,
+					Message:  message("failed to evaluate expression: no such key: labels"),
+					Code:     code(400),
+				},
+				Report: []ValidationResult{
+					{Name: "max-replicas", Index: 0, Result: ValidationPass, Severity: "high", Category: "Resources"},
+					{Name: "allowed-name", Index: 1, Result: ValidationPass},
+					{Index: 2, Result: ValidationError, Message: "failed to evaluate expression: no such key: labels", Severity: "low"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settingsPayload, err := json.Marshal(settings.Settings{Validations: validations, Report: true, FailurePolicy: test.failurePolicy})
+			require.NoError(t, err)
+
+			object, err := json.Marshal(test.object)
+			require.NoError(t, err)
+
+			payload, err := json.Marshal(kubewardenProtocol.ValidationRequest{
+				Request:  kubewardenProtocol.KubernetesAdmissionRequest{Object: object},
+				Settings: settingsPayload,
+			})
+			require.NoError(t, err)
+
+			response, err := Validate(payload)
+			require.NoError(t, err)
+
+			validationResponse := ValidationResponse{}
+			err = json.Unmarshal(response, &validationResponse)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedResponse, validationResponse)
+		})
+	}
+}
+
+func TestReportAgainstParamsList(t *testing.T) {
+	compiler, err := cel.NewCompiler()
+	require.NoError(t, err)
+
+	vars := map[string]any{
+		"object": map[string]any{"spec": map[string]any{"replicas": 4}},
+	}
+	paramsList := []any{
+		map[string]any{"maxReplicas": 5},
+		map[string]any{"maxReplicas": 3},
+		map[string]any{"maxReplicas": 10},
+	}
+	validations := []settings.Validation{
+		{
+			Name:       "max-replicas",
+			Expression: "object.spec.replicas <= params.maxReplicas",
+			Reason:     settings.StatusReasonInvalid,
+		},
+	}
+
+	response := evalReport(compiler, vars, paramsList, validations, false, admissionregistration.Fail)
+
+	assert.False(t, response.Accepted)
+	assert.Equal(t, []ValidationResult{
+		{
+			Name:    "max-replicas",
+			Index:   0,
+			Result:  ValidationFail,
+			Message: "max-replicas: failed expression: object.spec.replicas <= params.maxReplicas",
+			code:    400,
+		},
+	}, response.Report)
+}
+
+func TestReportAgainstParamsListIgnoringErrors(t *testing.T) {
+	compiler, err := cel.NewCompiler()
+	require.NoError(t, err)
+
+	vars := map[string]any{
+		"object": map[string]any{"spec": map[string]any{"replicas": 4}},
+	}
+	paramsList := []any{
+		map[string]any{"maxReplicas": 3},
+		map[string]any{},
+	}
+	validations := []settings.Validation{
+		{
+			Name:       "max-replicas",
+			Expression: "object.spec.replicas <= params.maxReplicas",
+			Reason:     settings.StatusReasonInvalid,
+		},
+	}
+
+	response := evalReport(compiler, vars, paramsList, validations, false, admissionregistration.Ignore)
+
+	assert.False(t, response.Accepted)
+	assert.Equal(t, []ValidationResult{
+		{
+			Name:    "max-replicas",
+			Index:   0,
+			Result:  ValidationFail,
+			Message: "max-replicas: failed expression: object.spec.replicas <= params.maxReplicas",
+			code:    400,
+		},
+	}, response.Report)
+}
@@ -0,0 +1,18 @@
+package validate
+
+import "github.com/kubewarden/policy-sdk-go/protocol"
+
+// failedValidationsAnnotation is the audit annotation holding the comma
+// separated names of the validations that rejected the request.
+// Outside of report mode the policy stops at the first failing validation,
+// so the annotation holds a single name.
+const failedValidationsAnnotation = "cel-policy/failed-validations"
+
+// ValidationResponse extends the Kubewarden validation response with the
+// audit annotations, which are not part of the protocol.ValidationResponse type,
+// and with the result of each validation when the policy runs in report mode.
+type ValidationResponse struct {
+	protocol.ValidationResponse
+	AuditAnnotations map[string]string  `json:"audit_annotations,omitempty"`
+	Report           []ValidationResult `json:"report,omitempty"`
+}
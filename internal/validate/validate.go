@@ -11,6 +11,7 @@ import (
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/kubewarden/cel-policy/internal/cel"
+	"github.com/kubewarden/cel-policy/internal/cel/library"
 	"github.com/kubewarden/cel-policy/internal/settings"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	"github.com/kubewarden/policy-sdk-go/protocol"
@@ -29,6 +30,8 @@ type ValidationRequest struct {
 }
 
 func Validate(payload []byte) ([]byte, error) {
+	library.ResetHostCache()
+
 	validationRequest := ValidationRequest{}
 
 	if err := json.Unmarshal(payload, &validationRequest); err != nil {
@@ -44,28 +47,75 @@ func Validate(payload []byte) ([]byte, error) {
 			kubewarden.Code(httpBadRequestStatusCode))
 	}
 
-	compiler, err := cel.NewCompiler()
+	compiler, err := cel.NewCompiler(validationRequest.Settings.TrustRoots...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CEL compiler: %w", err)
 	}
 
+	vars, requestMap, err := buildVars(request, validationRequest.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	paramsList, err := getEvaluationParams(validationRequest, requestMap)
+	if err != nil {
+		return handleFailureInParamsRetrieval(validationRequest, err.Error())
+	}
+
+	if err = evalVariables(compiler, vars, validationRequest.Settings.Variables); err != nil {
+		return nil, fmt.Errorf("failed to evaluate variables: %w", err)
+	}
+
+	if validationRequest.Settings.Report {
+		return json.Marshal(evalReport(
+			compiler,
+			vars,
+			paramsList,
+			validationRequest.Settings.Validations,
+			validationRequest.Settings.Explain,
+			validationRequest.Settings.FailurePolicy))
+	}
+
+	if len(paramsList) > 0 {
+		response, err := evalValidationsAgainstParamsList(
+			compiler,
+			vars,
+			paramsList,
+			validationRequest.Settings.Validations,
+			validationRequest.Settings.Explain)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(response)
+	}
+
+	response, err := evalValidations(compiler, vars, validationRequest.Settings.Validations, validationRequest.Settings.Explain)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(response)
+}
+
+// buildVars returns the variables available to the CEL expressions when
+// evaluating the given admission request, together with the request as a map.
+func buildVars(request protocol.KubernetesAdmissionRequest, rawRequest json.RawMessage) (map[string]interface{}, map[string]interface{}, error) {
 	object := map[string]interface{}{}
-	err = json.Unmarshal(request.Object, &object)
+	err := json.Unmarshal(request.Object, &object)
 	if err != nil {
-		return nil, fmt.Errorf("cannot unmarshal request object %w", err)
+		return nil, nil, fmt.Errorf("cannot unmarshal request object %w", err)
 	}
 
 	oldObject := map[string]interface{}{}
 	if request.OldObject != nil {
 		err = json.Unmarshal(request.OldObject, &oldObject)
 		if err != nil {
-			return nil, fmt.Errorf("cannot unmarshal request oldObject %w", err)
+			return nil, nil, fmt.Errorf("cannot unmarshal request oldObject %w", err)
 		}
 	}
 
 	requestMap := map[string]interface{}{}
-	if err = json.Unmarshal(validationRequest.Request, &requestMap); err != nil {
-		return nil, fmt.Errorf("cannot unmarshal request %w", err)
+	if err = json.Unmarshal(rawRequest, &requestMap); err != nil {
+		return nil, nil, fmt.Errorf("cannot unmarshal request %w", err)
 	}
 
 	vars := map[string]interface{}{
@@ -87,32 +137,7 @@ func Validate(payload []byte) ([]byte, error) {
 		},
 	}
 
-	paramsList, err := getEvaluationParams(validationRequest, requestMap)
-	if err != nil {
-		return handleFailureInParamsRetrieval(validationRequest, err.Error())
-	}
-
-	if err = evalVariables(compiler, vars, validationRequest.Settings.Variables); err != nil {
-		return nil, fmt.Errorf("failed to evaluate variables: %w", err)
-	}
-
-	if len(paramsList) > 0 {
-		response, err := evalValidationsAgainstParamsList(
-			compiler,
-			vars,
-			paramsList,
-			validationRequest.Settings.Validations)
-		if err != nil {
-			return nil, err
-		}
-		return json.Marshal(response)
-	}
-
-	response, err := evalValidations(compiler, vars, validationRequest.Settings.Validations)
-	if err != nil {
-		return nil, err
-	}
-	return json.Marshal(response)
+	return vars, requestMap, nil
 }
 
 func evalVariables(compiler *cel.Compiler, vars map[string]interface{}, variables []settings.Variable) error {
@@ -139,9 +164,9 @@ func evalVariables(compiler *cel.Compiler, vars map[string]interface{}, variable
 	return nil
 }
 
-func evalValidations(compiler *cel.Compiler, vars map[string]interface{}, validations []settings.Validation) (*protocol.ValidationResponse, error) {
+func evalValidations(compiler *cel.Compiler, vars map[string]interface{}, validations []settings.Validation, explain bool) (*ValidationResponse, error) {
 	for _, validation := range validations {
-		response, err := evaluateValidation(compiler, vars, validation)
+		response, err := evaluateValidation(compiler, vars, validation, explain)
 		if err != nil {
 			return nil, err
 		}
@@ -156,13 +181,22 @@ func evalValidations(compiler *cel.Compiler, vars map[string]interface{}, valida
 // evaluateValidation evaluates a single validation expression.
 // If the expression evaluates to false, it returns a rejection message and code.
 // If the expression evaluates to true, it returns empty message and code 0.
-func evaluateValidation(compiler *cel.Compiler, vars map[string]any, validation settings.Validation) (*protocol.ValidationResponse, error) {
+// The name of the validation, if any, prefixes the message and it is added to
+// the audit annotations. When explain is set, the explanation of the failure
+// is appended to the message.
+func evaluateValidation(compiler *cel.Compiler, vars map[string]any, validation settings.Validation, explain bool) (*ValidationResponse, error) {
 	ast, err := compiler.CompileCELExpression(validation.Expression)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile expression: %w", err)
 	}
 
-	val, err := compiler.EvalCELExpression(vars, ast)
+	var val ref.Val
+	var explanation []string
+	if explain {
+		val, explanation, err = compiler.ExplainCELExpression(vars, ast)
+	} else {
+		val, err = compiler.EvalCELExpression(vars, ast)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate expression: %w", err)
 	}
@@ -170,18 +204,27 @@ func evaluateValidation(compiler *cel.Compiler, vars map[string]any, validation
 	if val == types.False {
 		reason := reasonToStatusCode(validation.Reason)
 
-		if validation.MessageExpression != "" {
-			message, err := evalMessageExpression(compiler, vars, validation.MessageExpression)
+		var message string
+		switch {
+		case validation.MessageExpression != "":
+			message, err = evalMessageExpression(compiler, vars, validation.MessageExpression)
 			if err != nil {
 				return nil, fmt.Errorf("failed to evaluate message expression: %w", err)
 			}
-			return buildRejectResponse(kubewarden.Message(message), reason), nil
+		case validation.Message != "":
+			message = validation.Message
+		default:
+			message = fmt.Sprintf("failed expression: %s", strings.TrimSpace(validation.Expression))
 		}
-		if validation.Message != "" {
-			return buildRejectResponse(kubewarden.Message(validation.Message), reason), nil
+
+		if validation.Name != "" {
+			message = fmt.Sprintf("%s: %s", validation.Name, message)
+		}
+		if len(explanation) > 0 {
+			message = fmt.Sprintf("%s; explanation: %s", message, strings.Join(explanation, "; "))
 		}
 
-		return buildRejectResponse(kubewarden.Message(fmt.Sprintf("failed expression: %s", strings.TrimSpace(validation.Expression))), reason), nil
+		return buildRejectResponse(kubewarden.Message(message), reason, validation.Name), nil
 	}
 
 	return buildAcceptResponse(), nil
@@ -225,18 +268,32 @@ func reasonToStatusCode(reason string) kubewarden.Code {
 	return statusCode
 }
 
-func buildAcceptResponse() *protocol.ValidationResponse {
-	return &protocol.ValidationResponse{
-		Accepted: true,
+func buildAcceptResponse() *ValidationResponse {
+	return &ValidationResponse{
+		ValidationResponse: protocol.ValidationResponse{
+			Accepted: true,
+		},
 	}
 }
 
-func buildRejectResponse(message kubewarden.Message, code kubewarden.Code) *protocol.ValidationResponse {
+// buildRejectResponse returns the rejection of the validation with the given name.
+// Unnamed validations are not reported in the audit annotations.
+func buildRejectResponse(message kubewarden.Message, code kubewarden.Code, validationName string) *ValidationResponse {
 	messageStr := string(message)
 	codeUint16 := uint16(code)
-	return &protocol.ValidationResponse{
-		Accepted: false,
-		Message:  &messageStr,
-		Code:     &codeUint16,
+	response := &ValidationResponse{
+		ValidationResponse: protocol.ValidationResponse{
+			Accepted: false,
+			Message:  &messageStr,
+			Code:     &codeUint16,
+		},
 	}
+
+	if validationName != "" {
+		response.AuditAnnotations = map[string]string{
+			failedValidationsAnnotation: validationName,
+		}
+	}
+
+	return response
 }
@@ -167,6 +167,35 @@ func TestValidate(t *testing.T) {
 				Code:     code(400),
 			},
 		},
+		{
+			name: "test validation with explain",
+			settings: settings.Settings{
+				Validations: []settings.Validation{
+					{
+						Expression: "object.metadata.name.startsWith('app-') && object.spec.containers.all(c, c.image.startsWith('registry.io/') && c.name != 'sidecar')",
+						Message:    "not allowed",
+					},
+				},
+				Explain: true,
+			},
+			object: &corev1.Pod{
+				Metadata: &metav1.ObjectMeta{
+					Name:      "app-pod",
+					Namespace: "default",
+				},
+				Spec: &corev1.PodSpec{
+					Containers: []*corev1.Container{
+						{Name: message("app"), Image: "registry.io/app"},
+						{Name: message("sidecar"), Image: "docker.io/sidecar"},
+					},
+				},
+			},
+			expectedValidationResponse: kubewardenProtocol.ValidationResponse{
+				Accepted: false,
+				Message:  message(`not allowed; explanation: object.spec.containers[1]: c.image.startsWith("registry.io/") is false (c.image = "docker.io/sidecar"); object.spec.containers[1]: c.name != "sidecar" is false (c.name = "sidecar")`),
+				Code:     code(400),
+			},
+		},
 		{
 			name: "namespaceObject lazy loading",
 			settings: settings.Settings{
@@ -209,7 +238,7 @@ func TestValidate(t *testing.T) {
 	mockWapcClient := &mocks.MockWapcClient{}
 	mockWapcClient.On("HostCall", "kubewarden", "kubernetes", "get_resource", request).Return(response, nil)
 
-	host.Client = mockWapcClient
+	setHostClient(t, mockWapcClient)
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -250,3 +279,88 @@ func message(s string) *string {
 func code(i uint16) *uint16 {
 	return &i
 }
+
+func TestValidateNamedValidation(t *testing.T) {
+	policySettings := settings.Settings{
+		Validations: []settings.Validation{
+			{
+				Name:       "allowed-name",
+				Expression: "object.metadata.name != 'forbidden'",
+			},
+			{
+				Name:       "max-replicas",
+				Expression: "object.spec.replicas <= 5",
+				Message:    "too many replicas",
+			},
+		},
+	}
+	settingsPayload, err := json.Marshal(policySettings)
+	require.NoError(t, err)
+
+	object, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"name": "nginx"},
+		"spec":     map[string]any{"replicas": 10},
+	})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(kubewardenProtocol.ValidationRequest{
+		Request:  kubewardenProtocol.KubernetesAdmissionRequest{Object: object},
+		Settings: settingsPayload,
+	})
+	require.NoError(t, err)
+
+	response, err := Validate(payload)
+	require.NoError(t, err)
+
+	validationResponse := ValidationResponse{}
+	err = json.Unmarshal(response, &validationResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, ValidationResponse{
+		ValidationResponse: kubewardenProtocol.ValidationResponse{
+			Accepted: false,
+			Message:  message("max-replicas: too many replicas"),
+			Code:     code(400),
+		},
+		AuditAnnotations: map[string]string{
+			"cel-policy/failed-validations": "max-replicas",
+		},
+	}, validationResponse)
+}
+
+func TestValidateExplainShortCircuit(t *testing.T) {
+	// the mock fails the test if the namespace is fetched
+	mockWapcClient := &mocks.MockWapcClient{}
+	setHostClient(t, mockWapcClient)
+
+	settingsPayload, err := json.Marshal(settings.Settings{
+		Validations: []settings.Validation{
+			{
+				Expression: "object.metadata.name == 'nginx' || namespaceObject.metadata.labels.env == 'dev'",
+			},
+		},
+		Explain: true,
+	})
+	require.NoError(t, err)
+
+	object, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"name": "nginx", "namespace": "default"},
+	})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(kubewardenProtocol.ValidationRequest{
+		Request:  kubewardenProtocol.KubernetesAdmissionRequest{Namespace: "default", Object: object},
+		Settings: settingsPayload,
+	})
+	require.NoError(t, err)
+
+	response, err := Validate(payload)
+	require.NoError(t, err)
+
+	validationResponse := kubewardenProtocol.ValidationResponse{}
+	err = json.Unmarshal(response, &validationResponse)
+	require.NoError(t, err)
+
+	assert.True(t, validationResponse.Accepted)
+	mockWapcClient.AssertNotCalled(t, "HostCall")
+}
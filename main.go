@@ -4,12 +4,21 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 
 	"github.com/kubewarden/cel-policy/internal/settings"
 	"github.com/kubewarden/cel-policy/internal/validate"
 )
 
-const expectedArgsCount = 2
+const (
+	expectedArgsCount = 2
+	usage             = "use either 'validate', 'validate-settings', 'eval' or 'test'"
+)
+
+// subcommandsWithArgs are the subcommands accepting extra arguments.
+//
+//nolint:gochecknoglobals // []string cannot be const
+var subcommandsWithArgs = []string{"eval", "test"}
 
 func main() {
 	// Since we use log.Fatal* functions to write to stderr and exit with a non-zero status code,
@@ -17,32 +26,42 @@ func main() {
 	// be bubbled up to the message of the response returned to the caller.
 	log.SetFlags(0)
 
-	if len(os.Args) != expectedArgsCount {
-		log.Fatalln("Wrong usage, expected either 'validate' or 'validate-settings'")
-	}
-
-	input, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Panicf("Cannot read input: %v", err)
+	if len(os.Args) < expectedArgsCount || (!slices.Contains(subcommandsWithArgs, os.Args[1]) && len(os.Args) != expectedArgsCount) {
+		log.Fatalf("Wrong usage, %s", usage)
 	}
 
 	var response []byte
+	var err error
 
 	switch os.Args[1] {
 	case "validate":
-		response, err = validate.Validate(input)
+		response, err = validate.Validate(readInput())
 	case "validate-settings":
-		response, err = settings.ValidateSettings(input)
+		response, err = settings.ValidateSettings(readInput())
+	case "eval":
+		response, err = evalCommand(os.Args[2:], readInput())
+	case "test":
+		response, err = testCommand(os.Args[2:])
 	default:
-		log.Fatalf("wrong subcommand: '%s' - use either 'validate' or 'validate-settings'", os.Args[1])
+		log.Fatalf("wrong subcommand: '%s' - %s", os.Args[1], usage)
+	}
+
+	// the response is written even on failure, since it can describe the failure,
+	// e.g. the report of the failed test cases
+	if _, writeErr := os.Stdout.Write(response); writeErr != nil {
+		log.Fatalf("Cannot write response: %v", writeErr)
 	}
 
 	if err != nil {
 		log.Fatal(err)
 	}
+}
 
-	_, err = os.Stdout.Write(response)
+func readInput() []byte {
+	input, err := io.ReadAll(os.Stdin)
 	if err != nil {
-		log.Fatalf("Cannot write response: %v", err)
+		log.Panicf("Cannot read input: %v", err)
 	}
+
+	return input
 }
@@ -0,0 +1,29 @@
+//go:build !wasip1
+
+package main
+
+import (
+	"errors"
+
+	"github.com/kubewarden/cel-policy/internal/policytest"
+)
+
+// testCommand runs the policy test suites found at the given paths and
+// returns the report of the results.
+func testCommand(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, errors.New("wrong usage, expected the paths of the test suites")
+	}
+
+	results, err := policytest.Run(args)
+	if err != nil {
+		return nil, err
+	}
+
+	report, passed := policytest.Report(results)
+	if !passed {
+		return []byte(report), errors.New("some test cases failed")
+	}
+
+	return []byte(report), nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import "errors"
+
+// testCommand is not available in the policy module: the test runner and its
+// dependencies are left out of the WebAssembly build to keep the module small.
+// The test suites are run natively, e.g. with `go run . test <path>`.
+func testCommand([]string) ([]byte, error) {
+	return nil, errors.New("the test subcommand is not available in the policy module, run it with `go run . test`")
+}